@@ -0,0 +1,36 @@
+package cgroups
+
+// CpuacctSubsystem 和BlkioSubsystem只用来统计容器的资源使用 不做限制
+
+type CpuacctSubsystem struct {
+}
+
+func (s *CpuacctSubsystem) Name() string {
+	return "cpuacct"
+}
+
+func (s *CpuacctSubsystem) Create(cgroupPath string) error {
+	return createCgroupDir(cgroupPath)
+}
+
+func (s *CpuacctSubsystem) Set(cgroupPath string, res *ResourceConfig) error {
+	return nil
+}
+
+type BlkioSubsystem struct {
+}
+
+func (s *BlkioSubsystem) Name() string {
+	return "blkio"
+}
+
+func (s *BlkioSubsystem) Create(cgroupPath string) error {
+	return createCgroupDir(cgroupPath)
+}
+
+func (s *BlkioSubsystem) Set(cgroupPath string, res *ResourceConfig) error {
+	return nil
+}
+
+var _ Subsystem = new(CpuacctSubsystem)
+var _ Subsystem = new(BlkioSubsystem)
@@ -0,0 +1,137 @@
+package cgroups
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path"
+	"strconv"
+)
+
+// CgroupV1Manager 基于cgroup v1 在每个子系统的层级中各自管理容器的cgroup
+type CgroupV1Manager struct {
+	// 容器cgroup相对于各个层级挂载点的路径 如yocker/<id>
+	Path string
+	// 资源限制
+	Resource *ResourceConfig
+	// 子系统名到容器cgroup绝对路径的映射
+	paths map[string]string
+}
+
+// NewCgroupV1Manager mountInfo用来查找子系统的挂载点 宿主机上没有挂载的子系统会被跳过
+func NewCgroupV1Manager(mountInfo, relPath string) *CgroupV1Manager {
+	paths := make(map[string]string)
+	for _, subsystem := range subsystems {
+		mountpoint, err := FindCgroupMountpoint(mountInfo, subsystem.Name())
+		if err != nil {
+			logrus.Warnf("跳过 %s 子系统 %v", subsystem.Name(), err)
+			continue
+		}
+		paths[subsystem.Name()] = path.Join(mountpoint, relPath)
+	}
+	return &CgroupV1Manager{
+		Path:  relPath,
+		paths: paths,
+	}
+}
+
+// 遍历宿主机上可用的子系统
+func (c *CgroupV1Manager) each(fn func(subsystem Subsystem, cgroupPath string) error) error {
+	for _, subsystem := range subsystems {
+		cgroupPath, ok := c.paths[subsystem.Name()]
+		if !ok {
+			continue
+		}
+		if err := fn(subsystem, cgroupPath); err != nil {
+			return fmt.Errorf("%s 子系统 %v", subsystem.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (c *CgroupV1Manager) Set(res *ResourceConfig) error {
+	if res == nil {
+		return nil
+	}
+	err := c.each(func(subsystem Subsystem, cgroupPath string) error {
+		if err := subsystem.Create(cgroupPath); err != nil {
+			return err
+		}
+		return subsystem.Set(cgroupPath, res)
+	})
+	if err != nil {
+		return err
+	}
+	c.Resource = res
+	return nil
+}
+
+func (c *CgroupV1Manager) Apply(pid int) error {
+	return c.each(func(subsystem Subsystem, cgroupPath string) error {
+		if err := subsystem.Create(cgroupPath); err != nil {
+			return err
+		}
+		return writeFile(cgroupPath, "cgroup.procs", strconv.Itoa(pid))
+	})
+}
+
+func (c *CgroupV1Manager) Destroy() error {
+	return c.each(func(subsystem Subsystem, cgroupPath string) error {
+		if !pathExists(cgroupPath) {
+			return nil
+		}
+		if err := os.Remove(cgroupPath); err != nil {
+			return fmt.Errorf("删除cgroup %s 失败 %v", cgroupPath, err)
+		}
+		return nil
+	})
+}
+
+func (c *CgroupV1Manager) GetStats() (*Stats, error) {
+	stats := &Stats{}
+	var err error
+	if memoryPath, ok := c.paths["memory"]; ok {
+		if stats.MemoryUsage, err = readUint(memoryPath, "memory.usage_in_bytes"); err != nil {
+			return nil, err
+		}
+		if stats.MemoryLimit, err = readUint(memoryPath, "memory.limit_in_bytes"); err != nil {
+			return nil, err
+		}
+	}
+	if cpuacctPath, ok := c.paths["cpuacct"]; ok {
+		if stats.CpuUsage, err = readUint(cpuacctPath, "cpuacct.usage"); err != nil {
+			return nil, err
+		}
+	}
+	if pidsPath, ok := c.paths["pids"]; ok {
+		if stats.Pids, err = readUint(pidsPath, "pids.current"); err != nil {
+			return nil, err
+		}
+	}
+	if blkioPath, ok := c.paths["blkio"]; ok {
+		if stats.IoReadBytes, stats.IoWriteBytes, err = parseBlkioV1(blkioPath); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// GetPids 容器进程会加入所有子系统 从任意一个层级中读取即可
+func (c *CgroupV1Manager) GetPids() ([]int, error) {
+	for _, subsystem := range subsystems {
+		if cgroupPath, ok := c.paths[subsystem.Name()]; ok {
+			return readPids(cgroupPath)
+		}
+	}
+	return nil, fmt.Errorf("没有可用的cgroup子系统")
+}
+
+func (c *CgroupV1Manager) Freeze(frozen bool) error {
+	freezerPath, ok := c.paths["freezer"]
+	if !ok {
+		return fmt.Errorf("宿主机没有挂载freezer子系统")
+	}
+	return freezeV1(freezerPath, frozen)
+}
+
+var _ Manager = new(CgroupV1Manager)
@@ -0,0 +1,167 @@
+package cgroups
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// 需要在父cgroup中开启的控制器
+var controllers = []string{"cpu", "cpuset", "io", "memory", "pids"}
+
+// CgroupV2Manager 基于cgroup v2统一层级 管理一个容器的cgroup
+type CgroupV2Manager struct {
+	// 容器cgroup的绝对路径 如/sys/fs/cgroup/yocker/<id>
+	Path string
+	// 资源限制
+	Resource *ResourceConfig
+}
+
+// NewCgroupV2Manager root是统一层级的挂载点 relPath是容器cgroup相对于root的路径
+func NewCgroupV2Manager(root, relPath string) *CgroupV2Manager {
+	return &CgroupV2Manager{
+		Path: path.Join(root, relPath),
+	}
+}
+
+// 创建容器的cgroup目录 并在父cgroup中开启需要的控制器
+func (c *CgroupV2Manager) create() error {
+	parent := path.Dir(c.Path)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("创建cgroup %s 失败 %v", parent, err)
+	}
+	// v2中子cgroup能使用的控制器需要在父cgroup的subtree_control中开启
+	for _, dir := range []string{path.Dir(parent), parent} {
+		enableControllers(dir)
+	}
+	if err := os.MkdirAll(c.Path, 0755); err != nil {
+		return fmt.Errorf("创建cgroup %s 失败 %v", c.Path, err)
+	}
+	return nil
+}
+
+func enableControllers(dir string) {
+	available, err := readFile(dir, "cgroup.controllers")
+	if err != nil {
+		logrus.Warnf("读取 %s 可用控制器失败 %v", dir, err)
+		return
+	}
+	for _, controller := range controllers {
+		if !strings.Contains(" "+available+" ", " "+controller+" ") {
+			logrus.Warnf("%s 不支持 %s 控制器", dir, controller)
+			continue
+		}
+		if err := writeFile(dir, "cgroup.subtree_control", "+"+controller); err != nil {
+			logrus.Warnf("开启 %s 控制器失败 %v", controller, err)
+		}
+	}
+}
+
+// Set 把资源限制写入cgroup
+func (c *CgroupV2Manager) Set(res *ResourceConfig) error {
+	if res == nil {
+		return nil
+	}
+	if err := c.create(); err != nil {
+		return err
+	}
+	if res.MemoryLimit != "" {
+		memory, err := ParseMemory(res.MemoryLimit)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(c.Path, "memory.max", strconv.FormatInt(memory, 10)); err != nil {
+			return err
+		}
+	}
+	if res.Cpus != "" {
+		quota, period, err := ParseCpus(res.Cpus)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(c.Path, "cpu.max", fmt.Sprintf("%d %d", quota, period)); err != nil {
+			return err
+		}
+	}
+	if res.CpuShares != 0 {
+		weight := sharesToWeight(res.CpuShares)
+		if err := writeFile(c.Path, "cpu.weight", strconv.FormatUint(weight, 10)); err != nil {
+			return err
+		}
+	}
+	if res.CpusetCpus != "" {
+		if err := writeFile(c.Path, "cpuset.cpus", res.CpusetCpus); err != nil {
+			return err
+		}
+	}
+	if res.PidsLimit != 0 {
+		limit := "max"
+		if res.PidsLimit > 0 {
+			limit = strconv.FormatInt(res.PidsLimit, 10)
+		}
+		if err := writeFile(c.Path, "pids.max", limit); err != nil {
+			return err
+		}
+	}
+	c.Resource = res
+	return nil
+}
+
+func (c *CgroupV2Manager) GetStats() (*Stats, error) {
+	stats := &Stats{}
+	var err error
+	if stats.MemoryUsage, err = readUint(c.Path, "memory.current"); err != nil {
+		return nil, err
+	}
+	if stats.MemoryLimit, err = readUint(c.Path, "memory.max"); err != nil {
+		return nil, err
+	}
+	cpuStat, err := readKeyValue(c.Path, "cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+	// cpu.stat中的单位是微秒
+	stats.CpuUsage = cpuStat["usage_usec"] * 1000
+	if stats.Pids, err = readUint(c.Path, "pids.current"); err != nil {
+		return nil, err
+	}
+	// 没有开启io控制器时没有io.stat
+	if pathExists(path.Join(c.Path, "io.stat")) {
+		if stats.IoReadBytes, stats.IoWriteBytes, err = parseIoStatV2(c.Path); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+func (c *CgroupV2Manager) GetPids() ([]int, error) {
+	return readPids(c.Path)
+}
+
+func (c *CgroupV2Manager) Freeze(frozen bool) error {
+	return freezeV2(c.Path, frozen)
+}
+
+var _ Manager = new(CgroupV2Manager)
+
+// Apply 把进程加入到容器的cgroup中
+func (c *CgroupV2Manager) Apply(pid int) error {
+	if err := c.create(); err != nil {
+		return err
+	}
+	return writeFile(c.Path, "cgroup.procs", strconv.Itoa(pid))
+}
+
+// Destroy 删除容器的cgroup 只能用rmdir删除 cgroup中的文件不能删除
+func (c *CgroupV2Manager) Destroy() error {
+	if !pathExists(c.Path) {
+		return nil
+	}
+	if err := os.Remove(c.Path); err != nil {
+		return fmt.Errorf("删除cgroup %s 失败 %v", c.Path, err)
+	}
+	return nil
+}
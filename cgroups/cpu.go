@@ -0,0 +1,40 @@
+package cgroups
+
+import (
+	"strconv"
+)
+
+type CpuSubsystem struct {
+}
+
+func (s *CpuSubsystem) Name() string {
+	return "cpu"
+}
+
+func (s *CpuSubsystem) Create(cgroupPath string) error {
+	return createCgroupDir(cgroupPath)
+}
+
+func (s *CpuSubsystem) Set(cgroupPath string, res *ResourceConfig) error {
+	if res.Cpus != "" {
+		quota, period, err := ParseCpus(res.Cpus)
+		if err != nil {
+			return err
+		}
+		// 先写周期再写配额 否则配额可能因为大于旧的周期被拒绝
+		if err := writeFile(cgroupPath, "cpu.cfs_period_us", strconv.FormatInt(period, 10)); err != nil {
+			return err
+		}
+		if err := writeFile(cgroupPath, "cpu.cfs_quota_us", strconv.FormatInt(quota, 10)); err != nil {
+			return err
+		}
+	}
+	if res.CpuShares != 0 {
+		if err := writeFile(cgroupPath, "cpu.shares", strconv.FormatUint(res.CpuShares, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ Subsystem = new(CpuSubsystem)
@@ -0,0 +1,47 @@
+package cgroups
+
+import (
+	"path"
+)
+
+type CpusetSubsystem struct {
+}
+
+func (s *CpusetSubsystem) Name() string {
+	return "cpuset"
+}
+
+// Create cpuset中cpus和mems为空的cgroup不能加入进程 新建的每一级目录都要从父目录继承
+func (s *CpusetSubsystem) Create(cgroupPath string) error {
+	if pathExists(cgroupPath) {
+		return nil
+	}
+	parent := path.Dir(cgroupPath)
+	if !pathExists(parent) {
+		if err := s.Create(parent); err != nil {
+			return err
+		}
+	}
+	if err := createCgroupDir(cgroupPath); err != nil {
+		return err
+	}
+	for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+		value, err := readFile(parent, file)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(cgroupPath, file, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *CpusetSubsystem) Set(cgroupPath string, res *ResourceConfig) error {
+	if res.CpusetCpus == "" {
+		return nil
+	}
+	return writeFile(cgroupPath, "cpuset.cpus", res.CpusetCpus)
+}
+
+var _ Subsystem = new(CpusetSubsystem)
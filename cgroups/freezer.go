@@ -0,0 +1,93 @@
+package cgroups
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// 等待cgroup中的进程全部冻结或解冻的时间
+	freezeTimeout = 5 * time.Second
+	// v1 freezer.state中的状态
+	freezerFrozen = "FROZEN"
+	freezerThawed = "THAWED"
+)
+
+// FreezerSubsystem v1的freezer子系统 只用来暂停和恢复容器 不做资源限制
+type FreezerSubsystem struct {
+}
+
+func (s *FreezerSubsystem) Name() string {
+	return "freezer"
+}
+
+func (s *FreezerSubsystem) Create(cgroupPath string) error {
+	return createCgroupDir(cgroupPath)
+}
+
+func (s *FreezerSubsystem) Set(cgroupPath string, res *ResourceConfig) error {
+	return nil
+}
+
+var _ Subsystem = new(FreezerSubsystem)
+
+// 冻结不是立即完成的 轮询直到check返回true或者超时
+func waitFreezer(check func() (bool, error)) error {
+	deadline := time.Now().Add(freezeTimeout)
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待cgroup冻结状态变更超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// v1 写入FROZEN后freezer.state会先变为FREEZING 全部进程冻结后才变为FROZEN
+func freezeV1(cgroupPath string, frozen bool) error {
+	state := freezerThawed
+	if frozen {
+		state = freezerFrozen
+	}
+	return waitFreezer(func() (bool, error) {
+		// FREEZING状态下有新进程加入时需要重新写入
+		if err := writeFile(cgroupPath, "freezer.state", state); err != nil {
+			return false, err
+		}
+		current, err := readFile(cgroupPath, "freezer.state")
+		if err != nil {
+			return false, err
+		}
+		return current == state, nil
+	})
+}
+
+// v2 写入cgroup.freeze后 cgroup.events中的frozen字段表示是否已经全部冻结
+func freezeV2(cgroupPath string, frozen bool) error {
+	value := "0"
+	if frozen {
+		value = "1"
+	}
+	if err := writeFile(cgroupPath, "cgroup.freeze", value); err != nil {
+		return err
+	}
+	return waitFreezer(func() (bool, error) {
+		events, err := readFile(cgroupPath, "cgroup.events")
+		if err != nil {
+			return false, err
+		}
+		for _, line := range strings.Split(events, "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "frozen" {
+				return fields[1] == value, nil
+			}
+		}
+		return false, fmt.Errorf("cgroup.events中没有frozen字段")
+	})
+}
@@ -0,0 +1,48 @@
+package cgroups
+
+import (
+	"path"
+)
+
+const (
+	// cgroup的默认挂载点
+	defaultCgroupRoot = "/sys/fs/cgroup"
+	// 记录当前进程挂载信息的文件 用来查找v1各个子系统的挂载点
+	defaultMountInfo = "/proc/self/mountinfo"
+	// 所有容器的cgroup都放在这个目录下
+	yockerCgroupName = "yocker"
+)
+
+// Manager 管理一个容器的cgroup 屏蔽v1和v2的差异
+type Manager interface {
+	// Set 设置资源限制
+	Set(res *ResourceConfig) error
+	// Apply 把进程加入到cgroup
+	Apply(pid int) error
+	// Destroy 删除cgroup
+	Destroy() error
+	// GetStats 读取cgroup中的资源使用情况
+	GetStats() (*Stats, error)
+	// GetPids 获取cgroup中所有进程的pid
+	GetPids() ([]int, error)
+	// Freeze 冻结或解冻cgroup中的所有进程
+	Freeze(frozen bool) error
+}
+
+// NewCgroupManager 根据宿主机挂载的cgroup版本创建对应的manager
+func NewCgroupManager(containerId string) Manager {
+	return newCgroupManager(defaultCgroupRoot, defaultMountInfo, containerId)
+}
+
+func newCgroupManager(root, mountInfo, containerId string) Manager {
+	relPath := path.Join(yockerCgroupName, containerId)
+	if IsCgroup2UnifiedMode(root) {
+		return NewCgroupV2Manager(root, relPath)
+	}
+	return NewCgroupV1Manager(mountInfo, relPath)
+}
+
+// IsCgroup2UnifiedMode 只有v2的统一层级根目录下才有cgroup.controllers文件
+func IsCgroup2UnifiedMode(root string) bool {
+	return pathExists(path.Join(root, "cgroup.controllers"))
+}
@@ -0,0 +1,187 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+// 在临时目录中构造v1各个子系统的层级 返回指向这些层级的mountinfo
+func fakeCgroupV1(t *testing.T, subsystems ...string) (root, mountInfo string) {
+	t.Helper()
+	root = t.TempDir()
+	var lines string
+	for i, subsystem := range subsystems {
+		mountpoint := path.Join(root, subsystem)
+		if err := os.MkdirAll(mountpoint, 0755); err != nil {
+			t.Fatal(err)
+		}
+		lines += fmt.Sprintf("%d 25 0:%d / %s rw,nosuid,nodev,noexec,relatime shared:%d - cgroup cgroup rw,%s\n",
+			35+i, 30+i, mountpoint, 16+i, subsystem)
+	}
+	// 其他文件系统的挂载不应该被当成cgroup
+	lines += "22 1 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:5 - proc proc rw\n"
+	mountInfo = path.Join(root, "mountinfo")
+	writeTestFile(t, mountInfo, lines)
+	return root, mountInfo
+}
+
+// 构造v2的统一层级 根目录下有cgroup.controllers
+func fakeCgroupV2(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeTestFile(t, path.Join(root, "cgroup.controllers"), "cpuset cpu io memory pids\n")
+	writeTestFile(t, path.Join(root, "cgroup.subtree_control"), "")
+	return root
+}
+
+func writeTestFile(t *testing.T, file, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, dir, file string) string {
+	t.Helper()
+	content, err := readFile(dir, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestNewCgroupManagerDetectsVersion(t *testing.T) {
+	v2Root := fakeCgroupV2(t)
+	manager := newCgroupManager(v2Root, "/nonexistent", "c1")
+	v2, ok := manager.(*CgroupV2Manager)
+	if !ok {
+		t.Fatalf("统一层级应该使用v2 实际是 %T", manager)
+	}
+	if want := path.Join(v2Root, yockerCgroupName, "c1"); v2.Path != want {
+		t.Errorf("v2 cgroup路径 %s 期望 %s", v2.Path, want)
+	}
+
+	v1Root, mountInfo := fakeCgroupV1(t, "memory", "cpu")
+	manager = newCgroupManager(v1Root, mountInfo, "c1")
+	v1, ok := manager.(*CgroupV1Manager)
+	if !ok {
+		t.Fatalf("没有cgroup.controllers时应该使用v1 实际是 %T", manager)
+	}
+	if want := path.Join(v1Root, "memory", yockerCgroupName, "c1"); v1.paths["memory"] != want {
+		t.Errorf("memory cgroup路径 %s 期望 %s", v1.paths["memory"], want)
+	}
+	if _, ok := v1.paths["pids"]; ok {
+		t.Errorf("没有挂载的pids子系统应该被跳过")
+	}
+}
+
+func TestFindCgroupMountpoint(t *testing.T) {
+	root, mountInfo := fakeCgroupV1(t, "cpu,cpuacct", "memory")
+	// cpu和cpuacct挂载在同一个层级时挂载选项中有两个子系统
+	for subsystem, want := range map[string]string{
+		"memory":  path.Join(root, "memory"),
+		"cpuacct": path.Join(root, "cpu,cpuacct"),
+	} {
+		got, err := FindCgroupMountpoint(mountInfo, subsystem)
+		if err != nil {
+			t.Fatalf("查找 %s 失败 %v", subsystem, err)
+		}
+		if got != want {
+			t.Errorf("%s 挂载点 %s 期望 %s", subsystem, got, want)
+		}
+	}
+	if _, err := FindCgroupMountpoint(mountInfo, "proc"); err == nil {
+		t.Errorf("非cgroup文件系统不应该被找到")
+	}
+}
+
+func TestCgroupV1Set(t *testing.T) {
+	root, mountInfo := fakeCgroupV1(t, "memory", "cpu", "pids")
+	manager := newCgroupManager(root, mountInfo, "c1")
+	res := &ResourceConfig{MemoryLimit: "100m", Cpus: "0.5", CpuShares: 512, PidsLimit: 20}
+	if err := manager.Set(res); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		subsystem, file, want string
+	}{
+		{"memory", "memory.limit_in_bytes", "104857600"},
+		{"cpu", "cpu.cfs_period_us", "100000"},
+		{"cpu", "cpu.cfs_quota_us", "50000"},
+		{"cpu", "cpu.shares", "512"},
+		{"pids", "pids.max", "20"},
+	} {
+		dir := path.Join(root, tc.subsystem, yockerCgroupName, "c1")
+		if got := readTestFile(t, dir, tc.file); got != tc.want {
+			t.Errorf("%s 为 %s 期望 %s", tc.file, got, tc.want)
+		}
+	}
+}
+
+func TestCgroupV2Set(t *testing.T) {
+	root := fakeCgroupV2(t)
+	manager := newCgroupManager(root, "/nonexistent", "c1")
+	res := &ResourceConfig{MemoryLimit: "1g", Cpus: "1.5", CpuShares: 1024, CpusetCpus: "0-1", PidsLimit: -1}
+	if err := manager.Set(res); err != nil {
+		t.Fatal(err)
+	}
+	dir := path.Join(root, yockerCgroupName, "c1")
+	for file, want := range map[string]string{
+		"memory.max":  "1073741824",
+		"cpu.max":     "150000 100000",
+		"cpu.weight":  "39",
+		"cpuset.cpus": "0-1",
+		"pids.max":    "max",
+	} {
+		if got := readTestFile(t, dir, file); got != want {
+			t.Errorf("%s 为 %s 期望 %s", file, got, want)
+		}
+	}
+	// 根cgroup中需要为子cgroup开启控制器
+	if got := readTestFile(t, root, "cgroup.subtree_control"); got == "" {
+		t.Errorf("没有在根cgroup中开启控制器")
+	}
+}
+
+func TestCpusetInheritsFromParent(t *testing.T) {
+	root, mountInfo := fakeCgroupV1(t, "cpuset")
+	hierarchy := path.Join(root, "cpuset")
+	writeTestFile(t, path.Join(hierarchy, "cpuset.cpus"), "0-3\n")
+	writeTestFile(t, path.Join(hierarchy, "cpuset.mems"), "0\n")
+
+	manager := newCgroupManager(root, mountInfo, "c1")
+	if err := manager.Set(&ResourceConfig{MemoryLimit: "100m"}); err != nil {
+		t.Fatal(err)
+	}
+	// 中间的yocker目录和容器目录都要从上一级继承cpus和mems
+	for _, dir := range []string{
+		path.Join(hierarchy, yockerCgroupName),
+		path.Join(hierarchy, yockerCgroupName, "c1"),
+	} {
+		if got := readTestFile(t, dir, "cpuset.cpus"); got != "0-3" {
+			t.Errorf("%s 的cpuset.cpus为 %s 期望 0-3", dir, got)
+		}
+		if got := readTestFile(t, dir, "cpuset.mems"); got != "0" {
+			t.Errorf("%s 的cpuset.mems为 %s 期望 0", dir, got)
+		}
+	}
+
+	// 设置了cpuset时覆盖继承的值
+	if err := manager.Set(&ResourceConfig{CpusetCpus: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path.Join(hierarchy, yockerCgroupName, "c1"), "cpuset.cpus"); got != "1" {
+		t.Errorf("cpuset.cpus为 %s 期望 1", got)
+	}
+}
+
+func TestSharesToWeight(t *testing.T) {
+	for shares, want := range map[uint64]uint64{0: 0, 1: 1, 2: 1, 1024: 39, 262144: 10000, 300000: 10000} {
+		if got := sharesToWeight(shares); got != want {
+			t.Errorf("sharesToWeight(%d) = %d 期望 %d", shares, got, want)
+		}
+	}
+}
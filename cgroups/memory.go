@@ -0,0 +1,29 @@
+package cgroups
+
+import (
+	"strconv"
+)
+
+type MemorySubsystem struct {
+}
+
+func (s *MemorySubsystem) Name() string {
+	return "memory"
+}
+
+func (s *MemorySubsystem) Create(cgroupPath string) error {
+	return createCgroupDir(cgroupPath)
+}
+
+func (s *MemorySubsystem) Set(cgroupPath string, res *ResourceConfig) error {
+	if res.MemoryLimit == "" {
+		return nil
+	}
+	memory, err := ParseMemory(res.MemoryLimit)
+	if err != nil {
+		return err
+	}
+	return writeFile(cgroupPath, "memory.limit_in_bytes", strconv.FormatInt(memory, 10))
+}
+
+var _ Subsystem = new(MemorySubsystem)
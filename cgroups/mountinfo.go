@@ -0,0 +1,42 @@
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FindCgroupMountpoint 从mountinfo中找到v1子系统所在层级的挂载点
+// mountinfo的格式如下 以" - "分隔 前面第5列是挂载点 后面依次是文件系统类型 挂载源 和超级块选项
+// 35 25 0:30 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:16 - cgroup cgroup rw,memory
+func FindCgroupMountpoint(mountInfo, subsystem string) (string, error) {
+	f, err := os.Open(mountInfo)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.SplitN(line, " - ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[0])
+		postFields := strings.Fields(parts[1])
+		if len(fields) < 5 || len(postFields) < 3 || postFields[0] != "cgroup" {
+			continue
+		}
+		for _, opt := range strings.Split(postFields[2], ",") {
+			if opt == subsystem {
+				return fields[4], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("没有找到 %s 子系统的挂载点", subsystem)
+}
@@ -0,0 +1,29 @@
+package cgroups
+
+import (
+	"strconv"
+)
+
+type PidsSubsystem struct {
+}
+
+func (s *PidsSubsystem) Name() string {
+	return "pids"
+}
+
+func (s *PidsSubsystem) Create(cgroupPath string) error {
+	return createCgroupDir(cgroupPath)
+}
+
+func (s *PidsSubsystem) Set(cgroupPath string, res *ResourceConfig) error {
+	if res.PidsLimit == 0 {
+		return nil
+	}
+	limit := "max"
+	if res.PidsLimit > 0 {
+		limit = strconv.FormatInt(res.PidsLimit, 10)
+	}
+	return writeFile(cgroupPath, "pids.max", limit)
+}
+
+var _ Subsystem = new(PidsSubsystem)
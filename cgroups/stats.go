@@ -0,0 +1,122 @@
+package cgroups
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Stats cgroup中记录的容器资源使用情况
+type Stats struct {
+	// 当前内存使用 字节
+	MemoryUsage uint64 `json:"memory_usage"`
+	// 内存上限 没有限制时为0
+	MemoryLimit uint64 `json:"memory_limit"`
+	// 累计使用的cpu时间 纳秒
+	CpuUsage uint64 `json:"cpu_usage"`
+	// 当前进程数
+	Pids uint64 `json:"pids"`
+	// 块设备累计读写字节数
+	IoReadBytes  uint64 `json:"io_read_bytes"`
+	IoWriteBytes uint64 `json:"io_write_bytes"`
+}
+
+func readUint(dir, file string) (uint64, error) {
+	value, err := readFile(dir, file)
+	if err != nil {
+		return 0, err
+	}
+	// v2中没有限制时是max
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// 读取 key value 形式的文件 如v2的cpu.stat
+func readKeyValue(dir, file string) (map[string]uint64, error) {
+	f, err := os.Open(path.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = value
+	}
+	return values, scanner.Err()
+}
+
+// 解析v2的io.stat 每行是一个设备
+// 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+func parseIoStatV2(dir string) (read, write uint64, err error) {
+	f, err := os.Open(path.Join(dir, "io.stat"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "rbytes":
+				read += value
+			case "wbytes":
+				write += value
+			}
+		}
+	}
+	return read, write, scanner.Err()
+}
+
+// 解析v1的blkio.throttle.io_service_bytes
+// 8:0 Read 1459200
+// 8:0 Write 314773504
+func parseBlkioV1(dir string) (read, write uint64, err error) {
+	f, err := os.Open(path.Join(dir, "blkio.throttle.io_service_bytes"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[1] {
+		case "Read":
+			read += value
+		case "Write":
+			write += value
+		}
+	}
+	return read, write, scanner.Err()
+}
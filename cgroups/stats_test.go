@@ -0,0 +1,51 @@
+package cgroups
+
+import (
+	"path"
+	"testing"
+)
+
+func TestParseIoStatV2(t *testing.T) {
+	dir := t.TempDir()
+	// 空行和只有空白的行要跳过 不能越界
+	writeTestFile(t, path.Join(dir, "io.stat"), "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0\n"+
+		"\n"+
+		"   \n"+
+		"8:16\n"+
+		"8:16 rbytes=100 wbytes=200 bad rios=x\n")
+	read, write, err := parseIoStatV2(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != 1459300 || write != 314773704 {
+		t.Errorf("读写字节数为 %d %d 期望 1459300 314773704", read, write)
+	}
+}
+
+func TestParseBlkioV1(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, path.Join(dir, "blkio.throttle.io_service_bytes"), "8:0 Read 1459200\n"+
+		"8:0 Write 314773504\n"+
+		"\n"+
+		"8:0 Sync 10\n"+
+		"Total 314774704\n")
+	read, write, err := parseBlkioV1(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != 1459200 || write != 314773504 {
+		t.Errorf("读写字节数为 %d %d 期望 1459200 314773504", read, write)
+	}
+}
+
+func TestReadKeyValue(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, path.Join(dir, "cpu.stat"), "usage_usec 2500\n\nuser_usec x\nnr_periods 3 extra\n")
+	values, err := readKeyValue(dir, "cpu.stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values["usage_usec"] != 2500 {
+		t.Errorf("解析结果为 %v 期望只有usage_usec=2500", values)
+	}
+}
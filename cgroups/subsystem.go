@@ -0,0 +1,34 @@
+package cgroups
+
+import (
+	"fmt"
+	"os"
+)
+
+// Subsystem cgroup v1的子系统 每个子系统挂载在独立的层级上
+type Subsystem interface {
+	// Name 子系统名 和mountinfo中的挂载选项一致
+	Name() string
+	// Create 在子系统的层级中创建cgroup目录
+	Create(cgroupPath string) error
+	// Set 把资源限制写入cgroup
+	Set(cgroupPath string, res *ResourceConfig) error
+}
+
+// v1支持的子系统
+var subsystems = []Subsystem{
+	&MemorySubsystem{},
+	&CpuSubsystem{},
+	&CpusetSubsystem{},
+	&PidsSubsystem{},
+	&CpuacctSubsystem{},
+	&BlkioSubsystem{},
+	&FreezerSubsystem{},
+}
+
+func createCgroupDir(cgroupPath string) error {
+	if err := os.MkdirAll(cgroupPath, 0755); err != nil {
+		return fmt.Errorf("创建cgroup %s 失败 %v", cgroupPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package cgroups
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceConfig 容器的资源限制配置
+type ResourceConfig struct {
+	// 内存限制 如 100m 1g
+	MemoryLimit string `json:"memory_limit,omitempty"`
+	// 可使用的cpu核数 如 0.5 2
+	Cpus string `json:"cpus,omitempty"`
+	// 可使用的cpu 如 0-2 0,1
+	CpusetCpus string `json:"cpuset_cpus,omitempty"`
+	// cpu时间片权重 和docker的--cpu-shares一致 默认1024
+	CpuShares uint64 `json:"cpu_shares,omitempty"`
+	// 最大进程数
+	PidsLimit int64 `json:"pids_limit,omitempty"`
+}
+
+// IsEmpty 判断是否没有设置任何限制
+func (r *ResourceConfig) IsEmpty() bool {
+	return r == nil || (r.MemoryLimit == "" && r.Cpus == "" && r.CpusetCpus == "" && r.CpuShares == 0 && r.PidsLimit == 0)
+}
+
+// Merge 用update中设置了的值覆盖当前配置 返回新的配置
+func (r *ResourceConfig) Merge(update *ResourceConfig) *ResourceConfig {
+	merged := &ResourceConfig{}
+	if r != nil {
+		*merged = *r
+	}
+	if update == nil {
+		return merged
+	}
+	if update.MemoryLimit != "" {
+		merged.MemoryLimit = update.MemoryLimit
+	}
+	if update.Cpus != "" {
+		merged.Cpus = update.Cpus
+	}
+	if update.CpusetCpus != "" {
+		merged.CpusetCpus = update.CpusetCpus
+	}
+	if update.CpuShares != 0 {
+		merged.CpuShares = update.CpuShares
+	}
+	if update.PidsLimit != 0 {
+		merged.PidsLimit = update.PidsLimit
+	}
+	return merged
+}
+
+// String 用于ps中展示 如 mem=100m,cpus=0.5
+func (r *ResourceConfig) String() string {
+	if r.IsEmpty() {
+		return "-"
+	}
+	var limits []string
+	if r.MemoryLimit != "" {
+		limits = append(limits, "mem="+r.MemoryLimit)
+	}
+	if r.Cpus != "" {
+		limits = append(limits, "cpus="+r.Cpus)
+	}
+	if r.CpusetCpus != "" {
+		limits = append(limits, "cpuset="+r.CpusetCpus)
+	}
+	if r.CpuShares != 0 {
+		limits = append(limits, fmt.Sprintf("shares=%d", r.CpuShares))
+	}
+	if r.PidsLimit != 0 {
+		limits = append(limits, fmt.Sprintf("pids=%d", r.PidsLimit))
+	}
+	return strings.Join(limits, ",")
+}
@@ -0,0 +1,105 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cpu.max中的默认周期 单位微秒
+	defaultCpuPeriod = 100000
+)
+
+// ParseMemory 把 100m 1g 这样的内存大小转换成字节数
+func ParseMemory(memory string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(memory))
+	if s == "" {
+		return 0, fmt.Errorf("内存大小为空")
+	}
+	unit := int64(1)
+	switch s[len(s)-1] {
+	case 'b':
+		s = s[:len(s)-1]
+	case 'k':
+		unit = 1 << 10
+		s = s[:len(s)-1]
+	case 'm':
+		unit = 1 << 20
+		s = s[:len(s)-1]
+	case 'g':
+		unit = 1 << 30
+		s = s[:len(s)-1]
+	}
+	num, err := strconv.ParseFloat(s, 64)
+	if err != nil || num <= 0 {
+		return 0, fmt.Errorf("内存大小格式错误 %s", memory)
+	}
+	return int64(num * float64(unit)), nil
+}
+
+// ParseCpus 把 --cpus 转换成每个周期内可用的cpu时间 单位微秒
+func ParseCpus(cpus string) (quota int64, period int64, err error) {
+	num, err := strconv.ParseFloat(strings.TrimSpace(cpus), 64)
+	if err != nil || num <= 0 {
+		return 0, 0, fmt.Errorf("cpu核数格式错误 %s", cpus)
+	}
+	return int64(num * defaultCpuPeriod), defaultCpuPeriod, nil
+}
+
+// sharesToWeight 把v1的cpu.shares [2, 262144] 转换成v2的cpu.weight [1, 10000]
+func sharesToWeight(shares uint64) uint64 {
+	if shares == 0 {
+		return 0
+	}
+	if shares < 2 {
+		shares = 2
+	}
+	if shares > 262144 {
+		shares = 262144
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+func writeFile(dir, file, value string) error {
+	if err := ioutil.WriteFile(path.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("写入 %s 失败 %v", path.Join(dir, file), err)
+	}
+	return nil
+}
+
+func readFile(dir, file string) (string, error) {
+	content, err := ioutil.ReadFile(path.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// 读取cgroup.procs中的pid 每行一个
+func readPids(dir string) ([]int, error) {
+	content, err := readFile(dir, "cgroup.procs")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" {
+			continue
+		}
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("解析pid失败 %s %v", line, err)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+func pathExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
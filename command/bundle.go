@@ -0,0 +1,168 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"yocker/cgroups"
+	"yocker/container"
+	"yocker/oci"
+)
+
+// 没有指定namespace时容器创建的namespace
+var defaultNamespaces = []string{oci.UTSNamespace, oci.PIDNamespace, oci.MountNamespace, oci.NetworkNamespace, oci.IPCNamespace}
+
+// yocker支持创建的namespace 不支持user namespace
+var namespaceFlags = map[string]uintptr{
+	oci.PIDNamespace:     syscall.CLONE_NEWPID,
+	oci.NetworkNamespace: syscall.CLONE_NEWNET,
+	oci.MountNamespace:   syscall.CLONE_NEWNS,
+	oci.IPCNamespace:     syscall.CLONE_NEWIPC,
+	oci.UTSNamespace:     syscall.CLONE_NEWUTS,
+	oci.CgroupNamespace:  syscall.CLONE_NEWCGROUP,
+}
+
+// 容器要创建的namespace
+func namespacesOf(opts *RunOptions) []string {
+	if len(opts.Namespaces) == 0 {
+		return defaultNamespaces
+	}
+	return opts.Namespaces
+}
+
+func hasNamespace(opts *RunOptions, nsType string) bool {
+	for _, ns := range namespacesOf(opts) {
+		if ns == nsType {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneFlags(opts *RunOptions) uintptr {
+	var flags uintptr
+	for _, ns := range namespacesOf(opts) {
+		flags |= namespaceFlags[ns]
+	}
+	return flags
+}
+
+// 用bundle中的spec设置容器的rootfs 用户进程 挂载 namespace和资源限制
+// 容器名 后台运行 重启策略 网络等运行方式仍然由命令行参数指定 命令行中的资源限制会覆盖spec中的
+func applyBundle(opts *RunOptions, bundle string) error {
+	if opts.ImageName != "" || len(opts.Volumes) > 0 {
+		return fmt.Errorf("--bundle不能和-image -v同时使用")
+	}
+	bundle, err := filepath.Abs(bundle)
+	if err != nil {
+		return err
+	}
+	spec, err := oci.LoadSpec(bundle)
+	if err != nil {
+		return err
+	}
+	rootfs, err := spec.RootfsPath(bundle)
+	if err != nil {
+		return err
+	}
+
+	var namespaces []string
+	if spec.Linux != nil {
+		for _, ns := range spec.Linux.Namespaces {
+			if ns.Path != "" {
+				return fmt.Errorf("不支持加入已有的namespace %s", ns.Path)
+			}
+			if _, ok := namespaceFlags[ns.Type]; !ok {
+				return fmt.Errorf("不支持的namespace %s", ns.Type)
+			}
+			namespaces = append(namespaces, ns.Type)
+		}
+	}
+	// 没有mount namespace时pivot_root会影响宿主机 没有uts namespace时设置主机名会修改宿主机的主机名
+	if !spec.HasNamespace(oci.MountNamespace) {
+		return fmt.Errorf("spec中必须包含mount namespace")
+	}
+	if spec.Hostname != "" && !spec.HasNamespace(oci.UTSNamespace) {
+		return fmt.Errorf("设置hostname需要uts namespace")
+	}
+	if opts.NetworkName != "" && !spec.HasNamespace(oci.NetworkNamespace) {
+		return fmt.Errorf("加入网络需要network namespace")
+	}
+
+	mounts := []container.Mount{}
+	for _, m := range spec.Mounts {
+		flags, data := oci.ParseMountOptions(m.Options)
+		if m.Type == "bind" {
+			flags |= syscall.MS_BIND
+		}
+		mounts = append(mounts, container.Mount{
+			Source:      m.Source,
+			Destination: m.Destination,
+			Type:        m.Type,
+			Flags:       flags,
+			Data:        data,
+		})
+	}
+
+	opts.Bundle = bundle
+	opts.Rootfs = rootfs
+	opts.ReadonlyRootfs = spec.Root.Readonly
+	opts.Mounts = mounts
+	opts.Namespaces = namespaces
+	// 命令行中指定的命令 用户 工作目录和主机名优先
+	if len(opts.Cmd) == 0 {
+		opts.Cmd = spec.Process.Args
+	}
+	// 重启时容器信息中的环境变量已经包含了spec中的
+	if !opts.Restart {
+		opts.Env = append(spec.Process.Env, opts.Env...)
+	}
+	if opts.User == "" {
+		opts.User = fmt.Sprintf("%d:%d", spec.Process.User.UID, spec.Process.User.GID)
+	}
+	if opts.WorkingDir == "" {
+		opts.WorkingDir = spec.Process.Cwd
+	}
+	if opts.Hostname == "" {
+		opts.Hostname = spec.Hostname
+	}
+	if opts.Hooks == nil && spec.Hooks != nil {
+		checkHooks(spec.Hooks)
+		opts.Hooks = spec.Hooks
+	}
+	var resources *oci.LinuxResources
+	if spec.Linux != nil {
+		resources = spec.Linux.Resources
+	}
+	opts.Resource = specResource(resources).Merge(opts.Resource)
+	return nil
+}
+
+// 把spec中的linux.resources转换为yocker的资源限制
+func specResource(r *oci.LinuxResources) *cgroups.ResourceConfig {
+	res := &cgroups.ResourceConfig{}
+	if r == nil {
+		return res
+	}
+	if r.Memory != nil && r.Memory.Limit != nil && *r.Memory.Limit > 0 {
+		res.MemoryLimit = strconv.FormatInt(*r.Memory.Limit, 10)
+	}
+	if r.CPU != nil {
+		if r.CPU.Shares != nil {
+			res.CpuShares = *r.CPU.Shares
+		}
+		if r.CPU.Quota != nil && *r.CPU.Quota > 0 {
+			period := uint64(100000)
+			if r.CPU.Period != nil && *r.CPU.Period > 0 {
+				period = *r.CPU.Period
+			}
+			res.Cpus = strconv.FormatFloat(float64(*r.CPU.Quota)/float64(period), 'f', -1, 64)
+		}
+		res.CpusetCpus = r.CPU.Cpus
+	}
+	if r.Pids != nil {
+		res.PidsLimit = r.Pids.Limit
+	}
+	return res
+}
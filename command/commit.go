@@ -2,10 +2,11 @@ package command
 
 import (
 	"errors"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
-	"os/exec"
-	"yocker/fs"
+	"yocker/container"
+	"yocker/image"
 )
 
 var CommitCommand = &cli.Command{
@@ -26,13 +27,41 @@ var CommitCommand = &cli.Command{
 }
 
 func commitContainer(containerName, imageName string) {
+	containerInfo, err := container.GetContainerInfoByName(containerName)
+	if err != nil {
+		logrus.Errorf("获取容器信息失败 %s %v", containerName, err)
+		return
+	}
+	if err := containerInfo.CanTransition(container.OpCommit); err != nil {
+		logrus.Errorf("保存镜像失败 %v", err)
+		return
+	}
 	//mntURL := "/opt/yocker/yocker/merged"
 	//imageTar := "/opt/yocker/yocker/" + imageName + ".tar"
 
-	mntURL := fs.GetMerged(containerName)
-	imageTar := fs.GetImage(imageName)
+	// 镜像运行的容器只保存upper目录 作为新的layer放在镜像的layer之上
+	// bundle运行的容器没有镜像 整个rootfs作为一层
+	layerDir := containerInfo.UpperDir
+	if containerInfo.Rootfs != "" {
+		layerDir = containerInfo.Rootfs
+	} else if containerInfo.ImageID == "" {
+		logrus.Errorf("保存镜像失败 找不到容器 %s 使用的镜像", containerName)
+		return
+	}
+	if _, _, err := image.ParseReference(imageName); err != nil {
+		logrus.Errorf("%v", err)
+		return
+	}
 
-	if _, err := exec.Command("tar", "-czf", imageTar, "-C", mntURL, ".").CombinedOutput(); err != nil {
-		logrus.Errorf("压缩到tar失败")
+	err = image.Update(func(idx *image.Index) error {
+		img, err := idx.Commit(layerDir, containerInfo.ImageID, imageName)
+		if err != nil {
+			return err
+		}
+		fmt.Println(img.ID)
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("保存镜像失败 %v", err)
 	}
 }
@@ -0,0 +1,41 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"yocker/container"
+)
+
+var CreateCommand = &cli.Command{
+	Name:  "create",
+	Usage: "创建容器但不运行用户命令，之后通过start启动，yocker create [command]",
+	Flags: containerFlags,
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 && context.String("bundle") == "" {
+			logrus.Errorf("缺少启动命令或镜像名")
+			return errors.New("缺少启动命令或镜像名")
+		}
+		opts, err := parseRunOptions(context)
+		if err != nil {
+			return err
+		}
+		opts.WaitStart = true
+		return createContainerWithMonitor(opts)
+	},
+}
+
+// created状态的容器也由监控进程创建 监控进程持有管道 等到start时再发送用户命令
+func createContainerWithMonitor(opts *RunOptions) error {
+	opts.ContainerId = container.NewContainerId()
+	if opts.ContainerName == "" {
+		opts.ContainerName = opts.ContainerId
+	}
+	if err := startMonitor(opts); err != nil {
+		logrus.Errorf("创建容器失败 %v", err)
+		return err
+	}
+	fmt.Println(opts.ContainerId)
+	return nil
+}
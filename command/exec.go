@@ -54,6 +54,10 @@ func execContainer(cmdArr []string, containerName string) {
 		logrus.Errorf("获取容器信息失败 %s %v", containerName, err)
 		return
 	}
+	if err := containerInfo.CanTransition(container.OpExec); err != nil {
+		logrus.Errorf("进入容器失败 %v", err)
+		return
+	}
 	cmdStr := strings.Join(cmdArr, " ")
 	logrus.Infof("进入的容器是 %s 命令是 %s", containerInfo.Name, cmdStr)
 	cmd := exec.Command("/proc/self/exe", "exec")
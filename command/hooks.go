@@ -0,0 +1,58 @@
+package command
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"yocker/container"
+	"yocker/oci"
+)
+
+// 执行容器的某一类hook 状态中的bundle对于镜像运行的容器是容器信息所在的目录
+func runContainerHooks(containerInfo *container.ContainerInfo, name, status string) error {
+	hooks := containerInfo.Hooks.Get(name)
+	if len(hooks) == 0 {
+		return nil
+	}
+	bundle := containerInfo.Bundle
+	if bundle == "" {
+		bundle = filepath.Clean(fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Name))
+	}
+	state := &oci.State{
+		Version: oci.Version,
+		ID:      containerInfo.Id,
+		Status:  status,
+		Bundle:  bundle,
+		Annotations: map[string]string{
+			"yocker.name": containerInfo.Name,
+		},
+	}
+	state.Pid, _ = strconv.Atoi(strings.TrimSpace(containerInfo.Pid))
+	logrus.Infof("执行 %s hook %s", name, containerInfo.Name)
+	return oci.RunHooks(hooks, state)
+}
+
+// 容器停止或删除后执行poststop hook 失败时只记录日志
+func runPoststopHooks(containerInfo *container.ContainerInfo) {
+	if err := runContainerHooks(containerInfo, oci.HookPoststop, oci.StateStopped); err != nil {
+		logrus.Warnf("%v", err)
+	}
+}
+
+// 读取--hooks指定的文件 yocker不在容器内执行hook 不支持createContainer和startContainer
+func loadHooks(path string) (*oci.Hooks, error) {
+	hooks, err := oci.LoadHooks(path)
+	if err != nil {
+		return nil, err
+	}
+	checkHooks(hooks)
+	return hooks, nil
+}
+
+func checkHooks(hooks *oci.Hooks) {
+	if len(hooks.Get(oci.HookCreateContainer)) > 0 || len(hooks.Get(oci.HookStartContainer)) > 0 {
+		logrus.Warnf("不支持createContainer和startContainer hook 将被忽略")
+	}
+}
@@ -0,0 +1,199 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"yocker/image"
+)
+
+var ImagesCommand = &cli.Command{
+	Name:  "images",
+	Usage: "查看所有镜像，yocker images [name[:tag]]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "quiet",
+			Aliases: []string{"q"},
+			Usage:   "只输出镜像id",
+		},
+		&cli.BoolFlag{
+			Name:  "digests",
+			Usage: "输出镜像的完整digest",
+		},
+		&cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "过滤镜像 支持reference=<name[:tag]> dangling=true|false before=<image> since=<image>",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "输出格式 table或json",
+			Value: "table",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		format := context.String("format")
+		if format != "table" && format != "json" {
+			logrus.Errorf("不支持的输出格式 %s", format)
+			return fmt.Errorf("不支持的输出格式 %s", format)
+		}
+		filters := context.StringSlice("filter")
+		// 和docker一致 参数中的镜像名等同于reference过滤
+		if context.NArg() > 0 {
+			filters = append(filters, "reference="+context.Args().First())
+		}
+		return listImages(filters, context.Bool("quiet"), context.Bool("digests"), format)
+	},
+}
+
+func listImages(filters []string, quiet, digests bool, format string) error {
+	idx, err := image.Load()
+	if err != nil {
+		logrus.Errorf("读取镜像失败 %v", err)
+		return err
+	}
+	images, err := filterImages(idx, filters)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return err
+	}
+	// 最新的镜像在前面
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].Created.After(images[j].Created)
+	})
+
+	if quiet {
+		for _, img := range images {
+			if digests {
+				fmt.Println(img.ID)
+			} else {
+				fmt.Println(img.ShortID())
+			}
+		}
+		return nil
+	}
+	if format == "json" {
+		if images == nil {
+			images = []*image.Image{}
+		}
+		content, err := json.Marshal(images)
+		if err != nil {
+			logrus.Errorf("序列化镜像信息失败 %v", err)
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	if digests {
+		fmt.Fprint(w, "REPOSITORY\tTAG\tDIGEST\tIMAGE ID\tCREATED\tSIZE\n")
+	} else {
+		fmt.Fprint(w, "REPOSITORY\tTAG\tIMAGE ID\tCREATED\tSIZE\n")
+	}
+	for _, img := range images {
+		tags := img.RepoTags
+		// 没有tag的镜像显示为<none>
+		if len(tags) == 0 {
+			tags = []string{"<none>:<none>"}
+		}
+		for _, ref := range tags {
+			i := strings.LastIndex(ref, ":")
+			repository, tag := ref[:i], ref[i+1:]
+			created := img.Created.Format("2006-01-02 15:04:05")
+			size := formatBytes(uint64(img.Size))
+			if digests {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", repository, tag, img.ID, img.ShortID(), created, size)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", repository, tag, img.ShortID(), created, size)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		logrus.Errorf("flush失败 %v", err)
+		return err
+	}
+	return nil
+}
+
+// 按--filter过滤镜像 同一个key指定多次时满足其中一个即可 不同的key需要同时满足
+func filterImages(idx *image.Index, filters []string) ([]*image.Image, error) {
+	conditions := make(map[string][]string)
+	for _, filter := range filters {
+		kv := strings.SplitN(filter, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("过滤条件格式错误 %s 应为key=value", filter)
+		}
+		switch kv[0] {
+		case "reference", "dangling", "before", "since":
+		default:
+			return nil, fmt.Errorf("不支持的过滤条件 %s", kv[0])
+		}
+		conditions[kv[0]] = append(conditions[kv[0]], kv[1])
+	}
+
+	var result []*image.Image
+	for _, img := range idx.Images {
+		matched := true
+		for key, values := range conditions {
+			ok := false
+			for _, value := range values {
+				m, err := matchImage(idx, img, key, value)
+				if err != nil {
+					return nil, err
+				}
+				if m {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, img)
+		}
+	}
+	return result, nil
+}
+
+func matchImage(idx *image.Index, img *image.Image, key, value string) (bool, error) {
+	switch key {
+	case "reference":
+		// 没有指定tag时匹配所有tag
+		for _, ref := range img.RepoTags {
+			i := strings.LastIndex(ref, ":")
+			if ok, err := path.Match(value, ref[:i]); err != nil {
+				return false, fmt.Errorf("reference格式错误 %s", value)
+			} else if ok {
+				return true, nil
+			}
+			if ok, _ := path.Match(value, ref); ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "dangling":
+		if value != "true" && value != "false" {
+			return false, fmt.Errorf("dangling只能是true或false")
+		}
+		return (len(img.RepoTags) == 0) == (value == "true"), nil
+	case "before", "since":
+		target, err := idx.Lookup(value)
+		if err != nil {
+			return false, err
+		}
+		if key == "before" {
+			return img.Created.Before(target.Created), nil
+		}
+		return img.Created.After(target.Created), nil
+	}
+	return false, nil
+}
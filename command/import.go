@@ -0,0 +1,44 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+	"yocker/image"
+)
+
+var ImportCommand = &cli.Command{
+	Name:  "import",
+	Usage: "把rootfs的tar包(如docker export导出的)导入为镜像，yocker import file|- [image]",
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少tar包")
+			return errors.New("缺少tar包")
+		}
+		var r io.Reader = os.Stdin
+		if input := context.Args().Get(0); input != "-" {
+			file, err := os.Open(input)
+			if err != nil {
+				logrus.Errorf("打开tar包失败 %v", err)
+				return err
+			}
+			defer file.Close()
+			r = file
+		}
+		var img *image.Image
+		err := image.Update(func(idx *image.Index) error {
+			var err error
+			img, err = idx.Import(r, context.Args().Get(1))
+			return err
+		})
+		if err != nil {
+			logrus.Errorf("导入镜像失败 %v", err)
+			return err
+		}
+		fmt.Println(img.ID)
+		return nil
+	},
+}
@@ -1,16 +1,18 @@
 package command
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
-	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"yocker/container"
 )
 
 var InitCommand = &cli.Command{
@@ -18,72 +20,219 @@ var InitCommand = &cli.Command{
 	Usage: "内部方法，在容器内运行用户进程",
 	Action: func(context *cli.Context) error {
 		logrus.Infof("开始初始化")
-		cmd := context.Args().Get(0)
-		logrus.Infof("命令是%s", cmd)
 		err := runContainerInitProcess()
 		return err
 	},
 }
 
 func runContainerInitProcess() error {
-	cmdArr := ReadUserCommand()
-	if cmdArr == nil || len(cmdArr) == 0 {
+	config, err := ReadInitConfig()
+	if err != nil {
+		return err
+	}
+	if len(config.Args) == 0 {
 		return errors.New("获取用户命令失败")
 	}
 
-	SetUpMount()
+	if err := SetUpMount(config.Mounts, config.ReadonlyRootfs); err != nil {
+		return err
+	}
+	if config.Hostname != "" {
+		if err := syscall.Sethostname([]byte(config.Hostname)); err != nil {
+			logrus.Errorf("设置主机名失败 %v", err)
+			return err
+		}
+	}
+	cwd := config.Cwd
+	if cwd == "" {
+		cwd = "/"
+	}
+	if err := syscall.Chdir(cwd); err != nil {
+		logrus.Errorf("切换工作目录失败 %s %v", cwd, err)
+		return err
+	}
+	// 用户命令只使用启动信息中的环境变量 查找命令时也使用其中的PATH
+	os.Clearenv()
+	for _, env := range config.Env {
+		if kv := strings.SplitN(env, "=", 2); len(kv) == 2 {
+			os.Setenv(kv[0], kv[1])
+		}
+	}
+	var credential *syscall.Credential
+	if config.User != "" {
+		uid, gid, err := container.ParseUser(config.User)
+		if err != nil {
+			return err
+		}
+		credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	}
 
-	path, err := exec.LookPath(cmdArr[0])
+	path, err := exec.LookPath(config.Args[0])
 	if err != nil {
 		logrus.Errorf("获取命令的绝对路径失败 %v", err)
 		return nil
 	}
 
-	if err := syscall.Exec(path, cmdArr[0:], os.Environ()); err != nil {
+	if config.Init {
+		os.Exit(runAsInit(path, config.Args, credential))
+	}
+	if credential != nil {
+		if err := setCredential(credential); err != nil {
+			logrus.Errorf("切换用户失败 %v", err)
+			return err
+		}
+	}
+	if err := syscall.Exec(path, config.Args, os.Environ()); err != nil {
 		logrus.Errorf(err.Error())
 	}
 	return nil
 }
 
-func ReadUserCommand() []string {
+// 先清空附加组 再切换gid和uid 切换uid后就没有权限修改组了
+func setCredential(credential *syscall.Credential) error {
+	if err := syscall.Setgroups(nil); err != nil {
+		return err
+	}
+	if err := syscall.Setgid(int(credential.Gid)); err != nil {
+		return err
+	}
+	return syscall.Setuid(int(credential.Uid))
+}
+
+// 1号进程没有注册处理函数的信号会被内核忽略 用户命令作为1号进程时收不到SIGTERM 也不会回收孤儿进程
+// 所以init自己留在1号进程 以子进程运行用户命令 把收到的信号转发给它 并回收所有退出的子进程
+// 用户命令退出后返回它的退出码 被信号杀死时为128+信号值
+func runAsInit(path string, argv []string, credential *syscall.Credential) int {
+	// 先注册再启动子进程 不会漏掉子进程很快退出时的SIGCHLD
+	signals := make(chan os.Signal, 32)
+	signal.Notify(signals)
+
+	cmd := exec.Command(path)
+	cmd.Args = argv
+	cmd.Env = os.Environ()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	if err := cmd.Start(); err != nil {
+		logrus.Errorf("启动用户命令失败 %v", err)
+		return 127
+	}
+	childPid := cmd.Process.Pid
+
+	for sig := range signals {
+		switch sig {
+		case syscall.SIGCHLD:
+			if status, exited := reapChildren(childPid); exited {
+				return container.ExitCode(status)
+			}
+		// go运行时用SIGURG抢占goroutine 不是发给容器的信号
+		case syscall.SIGURG:
+		default:
+			if err := syscall.Kill(childPid, sig.(syscall.Signal)); err != nil && err != syscall.ESRCH {
+				logrus.Warnf("转发信号失败 %v %v", sig, err)
+			}
+		}
+	}
+	return 0
+}
+
+// 回收所有已经退出的子进程 包括被托管给1号进程的孤儿进程 用户命令退出时返回它的退出状态
+func reapChildren(childPid int) (syscall.WaitStatus, bool) {
+	var childStatus syscall.WaitStatus
+	childExited := false
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || pid <= 0 {
+			return childStatus, childExited
+		}
+		if pid == childPid {
+			childStatus, childExited = status, true
+		}
+	}
+}
+
+// ReadInitConfig 从fd 3读取run发送的启动信息 run写完后会关闭管道
+func ReadInitConfig() (*container.InitConfig, error) {
 	pipe := os.NewFile(uintptr(3), "pipe")
-	msg, err := ioutil.ReadAll(pipe)
-	if err != nil {
-		logrus.Errorf("从pipe中读取失败 %v", err)
-		return nil
+	defer pipe.Close()
+	var config container.InitConfig
+	if err := json.NewDecoder(pipe).Decode(&config); err != nil {
+		logrus.Errorf("从pipe中读取启动信息失败 %v", err)
+		return nil, err
 	}
-	msgStr := string(msg)
-	return strings.Split(msgStr, " ")
+	return &config, nil
 }
 
-func SetUpMount() {
+// SetUpMount 以当前工作目录为rootfs 挂载启动信息中的mounts后pivot_root
+// 在pivot_root之前挂载 bind挂载才能访问到宿主机上的目录
+func SetUpMount(mounts []container.Mount, readonly bool) error {
 	pwd, err := os.Getwd()
 	if err != nil {
 		logrus.Errorf("获取当前工作目录失败 %v", err)
-		return
+		return err
 	}
 	logrus.Infof("当前工作目录 %s", pwd)
+	if err := prepareRoot(pwd); err != nil {
+		logrus.Errorf("准备rootfs失败 %v", err)
+		return err
+	}
+	for _, m := range mounts {
+		if err := mountInRootfs(pwd, m); err != nil {
+			logrus.Errorf("挂载%s到容器失败 %v", m.Destination, err)
+			return err
+		}
+	}
 	err = PivotRoot(pwd)
 	if err != nil {
 		logrus.Errorf("privot root 失败 %v", err)
-		return
-	}
-	defaultMountFlags := syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV
-	err = syscall.Mount("proc", "/proc", "proc", uintptr(defaultMountFlags), "")
-	if err != nil {
-		logrus.Errorf("挂载proc到容器失败 %v", err)
-		return
+		return err
 	}
-	err = syscall.Mount("tmpfs", "/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_STRICTATIME, "mode=755")
-	if err != nil {
-		logrus.Errorf("挂载tmpfs到容器失败 %v", err)
-		return
+	if readonly {
+		if err := syscall.Mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			logrus.Errorf("把rootfs挂载为只读失败 %v", err)
+			return err
+		}
 	}
+	return nil
 }
 
-func PivotRoot(root string) error {
+// 把m挂载到rootfs中的对应位置
+func mountInRootfs(rootfs string, m container.Mount) error {
+	dest := filepath.Join(rootfs, m.Destination)
+	// bind挂载文件时挂载点也要是文件
+	if m.Flags&syscall.MS_BIND != 0 {
+		if info, err := os.Stat(m.Source); err == nil && !info.IsDir() {
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(dest, os.O_CREATE, 0644)
+			if err != nil {
+				return err
+			}
+			file.Close()
+		} else if err := os.MkdirAll(dest, 0755); err != nil {
+			return err
+		}
+	} else if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount(m.Source, dest, m.Type, m.Flags, m.Data); err != nil {
+		return err
+	}
+	// bind挂载时会忽略只读等选项 需要再remount一次
+	if m.Flags&syscall.MS_BIND != 0 && m.Flags&^(syscall.MS_BIND|syscall.MS_REC) != 0 {
+		return syscall.Mount("", dest, "", m.Flags|syscall.MS_REMOUNT, "")
+	}
+	return nil
+}
 
-	// 要求不能是同一文件系统
+// pivot_root要求新的root是一个挂载点 并且和旧的root不在同一个文件系统
+func prepareRoot(root string) error {
 	//err := exec.Command("mount", "--make-rprivate", "/").Run()
 	err := syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, "")
 	if err != nil {
@@ -94,6 +243,10 @@ func PivotRoot(root string) error {
 	if err := syscall.Mount(root, root, "bind", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
 		return fmt.Errorf("挂载rootfs到它本身失败 %v", err)
 	}
+	return nil
+}
+
+func PivotRoot(root string) error {
 	pivotDir := filepath.Join(root, ".pivot_root")
 	if err := os.Mkdir(pivotDir, 0777); err != nil {
 		return err
@@ -0,0 +1,36 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"yocker/container"
+)
+
+var InspectCommand = &cli.Command{
+	Name:  "inspect",
+	Usage: "查看容器详细信息",
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少容器名")
+			return errors.New("缺少容器名")
+		}
+		var infos []*container.ContainerInfo
+		for _, containerName := range context.Args().Slice() {
+			containerInfo, err := container.GetContainerInfoByName(containerName)
+			if err != nil {
+				return err
+			}
+			infos = append(infos, containerInfo)
+		}
+		content, err := json.MarshalIndent(infos, "", "    ")
+		if err != nil {
+			logrus.Errorf("序列化容器信息失败 %v", err)
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	},
+}
@@ -0,0 +1,93 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"strconv"
+	"syscall"
+	"yocker/cgroups"
+	"yocker/container"
+)
+
+var KillCommand = &cli.Command{
+	Name:  "kill",
+	Usage: "给容器发送信号，yocker kill [-s SIGNAL] [--all] [container...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "signal",
+			Aliases: []string{"s"},
+			Usage:   "要发送的信号 如SIGHUP HUP 1",
+			Value:   "SIGKILL",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "发送给容器cgroup中的所有进程 而不只是容器的init进程",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少容器名")
+			return errors.New("缺少容器名")
+		}
+		sig, err := container.ParseSignal(context.String("signal"))
+		if err != nil {
+			logrus.Errorf("解析信号失败 %v", err)
+			return err
+		}
+		for _, containerName := range context.Args().Slice() {
+			if err := killContainer(containerName, sig, context.Bool("all")); err != nil {
+				logrus.Errorf("发送信号失败 %s %v", containerName, err)
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func killContainer(containerName string, sig syscall.Signal, all bool) error {
+	containerInfo, err := container.GetContainerInfoByName(containerName)
+	if err != nil {
+		return err
+	}
+	// 加锁后发送信号 暂停的容器发送信号后再解冻 状态按状态转移表改为running
+	_, err = container.ModifyContainerInfo(containerInfo.Name, func(containerInfo *container.ContainerInfo) error {
+		paused := containerInfo.Status == container.Paused
+		if err := containerInfo.Transition(container.OpKill); err != nil {
+			return err
+		}
+		if err := signalContainer(containerInfo, sig, all); err != nil {
+			return err
+		}
+		if paused {
+			if err := cgroups.NewCgroupManager(containerInfo.Id).Freeze(false); err != nil {
+				return fmt.Errorf("解冻容器失败 %v", err)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// 给容器的init进程或者cgroup中的所有进程发送信号
+func signalContainer(containerInfo *container.ContainerInfo, sig syscall.Signal, all bool) error {
+	if all {
+		pids, err := cgroups.NewCgroupManager(containerInfo.Id).GetPids()
+		if err != nil {
+			return fmt.Errorf("获取容器进程失败 %v", err)
+		}
+		for _, pid := range pids {
+			// 进程可能在读取pid之后已经退出
+			if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+				return err
+			}
+		}
+		return nil
+	}
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return fmt.Errorf("转化容器的pid失败 %v", err)
+	}
+	return syscall.Kill(pid, sig)
+}
@@ -1,14 +1,14 @@
 package command
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
-	"io/ioutil"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"yocker/container"
+	"yocker/image"
 )
 
 var ListCommand = &cli.Command{
@@ -21,35 +21,34 @@ var ListCommand = &cli.Command{
 }
 
 func listContainers() {
-	// dirURL /var/run/yocker
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, "")
-	dirURL = dirURL[:len(dirURL)-1]
-
-	files, err := ioutil.ReadDir(dirURL)
+	containers, err := container.ListContainers()
 	if err != nil {
-		logrus.Errorf("读取容器文件目录失败 %v", err)
 		return
 	}
-	var containers []*container.ContainerInfo
-	for _, file := range files {
-		tmpContainer, err := getContainerInfo(file)
-		if err != nil {
-			logrus.Errorf("读取容器信息失败 %v", err)
-			continue
-		}
-		containers = append(containers, tmpContainer)
+
+	// 读取镜像失败时只显示容器记录的镜像id
+	idx, err := image.Load()
+	if err != nil {
+		logrus.Warnf("读取镜像失败 %v", err)
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
-	fmt.Fprint(w, "ID\tNAME\tPID\tSTATUS\tCOMMAND\tCREATED\n")
+	fmt.Fprint(w, "ID\tNAME\tIMAGE\tPID\tSTATUS\tCOMMAND\tCREATED\tRESTARTS\tLIMITS\n")
 	for _, item := range containers{
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		status := item.Status
+		if status == container.Exit {
+			status = fmt.Sprintf("%s (%d)", status, item.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
 			item.Id,
 			item.Name,
+			imageOf(idx, item),
 			item.Pid,
-			item.Status,
-			item.Command,
-			item.CreateTime)
+			status,
+			strings.Join(item.Command, " "),
+			item.CreateTime,
+			item.RestartCount,
+			item.Resource.String())
 	}
 	if err := w.Flush(); err != nil{
 		logrus.Errorf("flush失败 %v", err)
@@ -57,24 +56,21 @@ func listContainers() {
 	}
 }
 
-func getContainerInfo(file os.FileInfo) (*container.ContainerInfo, error) {
-	if !file.IsDir(){
-		return nil, fmt.Errorf("非目录文件 %s", file.Name())
+// 镜像运行的容器显示镜像名 镜像名已经指向其他镜像时显示镜像id bundle运行的容器显示bundle目录
+func imageOf(idx *image.Index, containerInfo *container.ContainerInfo) string {
+	if containerInfo.Bundle != "" {
+		return containerInfo.Bundle
 	}
-	containerName := file.Name()
-	configFileDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFileDir = configFileDir + container.ConfigName
-
-	content, err := ioutil.ReadFile(configFileDir)
-	if err != nil{
-		logrus.Errorf("读取容器文件失败 %v", err)
-		return nil, err
+	if containerInfo.ImageID == "" {
+		if containerInfo.ImageName != "" {
+			return containerInfo.ImageName
+		}
+		return "-"
 	}
-	var containerInfo container.ContainerInfo
-	err = json.Unmarshal(content, &containerInfo)
-	if err != nil{
-		logrus.Errorf("序列化容器信息失败")
-		return nil, err
+	if idx != nil && containerInfo.ImageName != "" {
+		if img, err := idx.Lookup(containerInfo.ImageName); err == nil && img.ID == containerInfo.ImageID {
+			return containerInfo.ImageName
+		}
 	}
-	return &containerInfo, nil
+	return image.ShortID(containerInfo.ImageID)
 }
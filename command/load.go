@@ -0,0 +1,55 @@
+package command
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+	"yocker/image"
+)
+
+var LoadCommand = &cli.Command{
+	Name:  "load",
+	Usage: "导入docker save生成的tar包或OCI镜像布局的tar包，yocker load [-i image.tar]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Usage:   "读取的tar包 默认从标准输入读取",
+		},
+		&cli.BoolFlag{
+			Name:    "quiet",
+			Aliases: []string{"q"},
+			Usage:   "不输出导入的镜像",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		var r io.Reader = os.Stdin
+		if input := context.String("input"); input != "" {
+			file, err := os.Open(input)
+			if err != nil {
+				logrus.Errorf("打开镜像包失败 %v", err)
+				return err
+			}
+			defer file.Close()
+			r = file
+		}
+		loaded, err := image.LoadArchive(r)
+		if err != nil {
+			logrus.Errorf("导入镜像失败 %v", err)
+			return err
+		}
+		if context.Bool("quiet") {
+			return nil
+		}
+		for _, item := range loaded {
+			if item.Ref != "" {
+				fmt.Printf("Loaded image: %s\n", item.Ref)
+			} else {
+				fmt.Printf("Loaded image ID: %s\n", item.Image.ID)
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,223 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+	"yocker/container"
+)
+
+const (
+	// 监控进程从这个fd读取容器的启动参数
+	monitorOptionsFd = 3
+	// 监控进程通过这个fd告诉run命令容器是否启动成功
+	monitorReadyFd = 4
+)
+
+var MonitorCommand = &cli.Command{
+	Name:  "monitor",
+	Usage: "内部方法，作为后台容器的父进程等待容器退出并记录退出状态",
+	Action: func(context *cli.Context) error {
+		return runContainerMonitor()
+	},
+}
+
+// 监控进程启动容器的结果 Error为空表示启动成功
+type monitorReady struct {
+	Error string `json:"error"`
+}
+
+// 启动监控进程 并等待它把容器启动起来
+func startMonitor(opts *RunOptions) error {
+	optsRead, optsWrite, err := NewPipe()
+	if err != nil {
+		return fmt.Errorf("创建管道失败 %v", err)
+	}
+	readyRead, readyWrite, err := NewPipe()
+	if err != nil {
+		return fmt.Errorf("创建管道失败 %v", err)
+	}
+	defer readyRead.Close()
+
+	cmd := exec.Command("/proc/self/exe", "monitor")
+	// 脱离当前会话 run命令退出或者终端关闭都不会影响监控进程
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.ExtraFiles = []*os.File{optsRead, readyWrite}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动监控进程失败 %v", err)
+	}
+	optsRead.Close()
+	readyWrite.Close()
+
+	err = json.NewEncoder(optsWrite).Encode(opts)
+	optsWrite.Close()
+	if err != nil {
+		return fmt.Errorf("发送容器参数失败 %v", err)
+	}
+
+	content, err := ioutil.ReadAll(readyRead)
+	if err != nil {
+		return fmt.Errorf("读取监控进程结果失败 %v", err)
+	}
+	if len(content) == 0 {
+		return errors.New("监控进程异常退出")
+	}
+	var ready monitorReady
+	if err := json.Unmarshal(content, &ready); err != nil {
+		return fmt.Errorf("解析监控进程结果失败 %v", err)
+	}
+	if ready.Error != "" {
+		return errors.New(ready.Error)
+	}
+	return cmd.Process.Release()
+}
+
+func runContainerMonitor() error {
+	// 容器进程不能继承这个fd 否则run命令要等到容器退出才能读到EOF
+	syscall.CloseOnExec(monitorReadyFd)
+	readyPipe := os.NewFile(uintptr(monitorReadyFd), "ready")
+	optsPipe := os.NewFile(uintptr(monitorOptionsFd), "options")
+
+	var opts RunOptions
+	err := json.NewDecoder(optsPipe).Decode(&opts)
+	optsPipe.Close()
+	if err != nil {
+		err = fmt.Errorf("读取容器参数失败 %v", err)
+		notifyReady(readyPipe, err)
+		return err
+	}
+
+	c, err := createContainer(&opts)
+	if err == nil {
+		// 启动失败时start已经撤销了创建的容器
+		if opts.WaitStart {
+			if err = container.CreateStartFifo(opts.ContainerName); err != nil {
+				c.rollback()
+			}
+		} else {
+			err = c.start()
+		}
+	}
+	notifyReady(readyPipe, err)
+	if err != nil {
+		return err
+	}
+
+	// 监控进程之后的日志写到容器目录下
+	logFilePath := fmt.Sprintf(container.DefaultInfoLocation, opts.ContainerName) + container.MonitorLogFile
+	if logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+		logrus.SetOutput(logFile)
+		defer logFile.Close()
+	}
+
+	if opts.WaitStart {
+		started, err := container.WaitStartSignal(opts.ContainerName)
+		if err == nil && started {
+			err = c.start()
+		}
+		// 容器被删除或者启动失败 结束容器进程
+		if err != nil || !started {
+			logrus.Infof("容器没有启动 %s %v", opts.ContainerName, err)
+			c.parent.Process.Kill()
+			waitProcess(c.parent.Process.Pid)
+			return err
+		}
+	}
+
+	if err := superviseContainer(&opts, c); err != nil {
+		return err
+	}
+	autoRemoveContainer(opts.ContainerName)
+	return nil
+}
+
+// 等待容器进程退出并记录退出状态 需要时按重启策略重启容器
+func superviseContainer(opts *RunOptions, c *createdContainer) error {
+	var backoff time.Duration
+	for {
+		startedAt := time.Now()
+		status, err := waitProcess(c.parent.Process.Pid)
+		if err != nil {
+			logrus.Errorf("等待容器进程退出失败 %s %v", opts.ContainerName, err)
+			return err
+		}
+		logrus.Infof("容器进程退出 %s %v", opts.ContainerName, status)
+		if err := container.RecordContainerExit(opts.ContainerName, c.parent.Process.Pid, status); err != nil {
+			return err
+		}
+
+		containerInfo, err := container.GetContainerInfoByName(opts.ContainerName)
+		if err != nil || !container.ShouldRestart(containerInfo) {
+			return err
+		}
+		backoff = container.RestartBackoff(backoff, time.Since(startedAt))
+		logrus.Infof("%v 后重启容器 %s", backoff, opts.ContainerName)
+		time.Sleep(backoff)
+
+		// 等待期间容器可能被stop或者rm
+		containerInfo, err = container.GetContainerInfoByName(opts.ContainerName)
+		if err != nil || !container.ShouldRestart(containerInfo) {
+			return err
+		}
+		c, err = relaunchContainer(opts, containerInfo)
+		if err != nil {
+			logrus.Errorf("重启容器失败 %s %v", opts.ContainerName, err)
+			return err
+		}
+	}
+}
+
+// 在已经存在的容器中重新创建容器进程 并运行用户命令
+func relaunchContainer(opts *RunOptions, containerInfo *container.ContainerInfo) (*createdContainer, error) {
+	restartOpts := *opts
+	restartOpts.Restart = true
+	restartOpts.WaitStart = false
+	// 资源限制可能被update修改过
+	restartOpts.Resource = containerInfo.Resource
+	c, err := createContainer(&restartOpts)
+	if err != nil {
+		return nil, err
+	}
+	c.info, err = container.ModifyContainerInfo(opts.ContainerName, func(containerInfo *container.ContainerInfo) error {
+		containerInfo.RestartCount++
+		return nil
+	})
+	if err != nil {
+		c.rollback()
+		return nil, err
+	}
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func notifyReady(pipe *os.File, err error) {
+	defer pipe.Close()
+	ready := monitorReady{}
+	if err != nil {
+		ready.Error = err.Error()
+	}
+	if err := json.NewEncoder(pipe).Encode(ready); err != nil {
+		logrus.Errorf("发送容器启动结果失败 %v", err)
+	}
+}
+
+// 用wait4等待容器进程退出 返回进程的退出状态
+func waitProcess(pid int) (syscall.WaitStatus, error) {
+	var status syscall.WaitStatus
+	for {
+		_, err := syscall.Wait4(pid, &status, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		return status, err
+	}
+}
@@ -0,0 +1,137 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"os"
+	"strings"
+	"syscall"
+	"yocker/cgroups"
+	"yocker/container"
+	"yocker/oci"
+)
+
+// 生成的spec中没有PATH时使用 否则按bundle运行时找不到命令
+const defaultPathEnv = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+var OciSpecCommand = &cli.Command{
+	Name:  "oci-spec",
+	Usage: "输出run使用相同参数时对应的OCI runtime spec，yocker oci-spec [run的参数] [command]",
+	Flags: containerFlags,
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 && context.String("bundle") == "" {
+			logrus.Errorf("缺少启动命令")
+			return errors.New("缺少启动命令")
+		}
+		opts, err := parseRunOptions(context)
+		if err != nil {
+			return err
+		}
+		if opts.ContainerName == "" {
+			opts.ContainerName = "yocker"
+		}
+		// 命令中常有&和<> 不转义输出更容易阅读
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "    ")
+		if err := encoder.Encode(newSpec(opts)); err != nil {
+			logrus.Errorf("生成spec失败 %v", err)
+			return err
+		}
+		return nil
+	},
+}
+
+// 根据容器参数生成spec 镜像运行的容器rootfs为bundle中的rootfs目录
+func newSpec(opts *RunOptions) *oci.Spec {
+	config := newInitConfig(opts)
+	process := &oci.Process{
+		Args: config.Args,
+		Env:  opts.Env,
+		Cwd:  config.Cwd,
+	}
+	if process.Cwd == "" {
+		process.Cwd = "/"
+	}
+	if !hasEnv(process.Env, "PATH") {
+		process.Env = append([]string{defaultPathEnv}, process.Env...)
+	}
+	if opts.User != "" {
+		uid, gid, _ := container.ParseUser(opts.User)
+		process.User = oci.User{UID: uint32(uid), GID: uint32(gid)}
+	}
+
+	spec := &oci.Spec{
+		Version:  oci.Version,
+		Process:  process,
+		Root:     &oci.Root{Path: "rootfs", Readonly: opts.ReadonlyRootfs},
+		Hostname: config.Hostname,
+		Hooks:    opts.Hooks,
+		Linux:    &oci.Linux{Resources: resourceToSpec(opts.Resource)},
+	}
+	if opts.Rootfs != "" {
+		spec.Root.Path = opts.Rootfs
+	}
+	for _, m := range config.Mounts {
+		spec.Mounts = append(spec.Mounts, oci.Mount{
+			Destination: m.Destination,
+			Type:        m.Type,
+			Source:      m.Source,
+			Options:     oci.MountOptions(m.Flags, m.Data),
+		})
+	}
+	// -v 挂载的目录
+	for _, volume := range opts.Volumes {
+		if volumeURLs := strings.Split(volume, ":"); len(volumeURLs) == 2 {
+			spec.Mounts = append(spec.Mounts, oci.Mount{
+				Destination: volumeURLs[1],
+				Type:        "bind",
+				Source:      volumeURLs[0],
+				Options:     oci.MountOptions(syscall.MS_BIND|syscall.MS_REC, ""),
+			})
+		}
+	}
+	for _, ns := range namespacesOf(opts) {
+		spec.Linux.Namespaces = append(spec.Linux.Namespaces, oci.LinuxNamespace{Type: ns})
+	}
+	return spec
+}
+
+func hasEnv(envs []string, key string) bool {
+	for _, env := range envs {
+		if strings.HasPrefix(env, key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// 把yocker的资源限制转换为spec中的linux.resources
+func resourceToSpec(res *cgroups.ResourceConfig) *oci.LinuxResources {
+	if res.IsEmpty() {
+		return nil
+	}
+	r := &oci.LinuxResources{}
+	if res.MemoryLimit != "" {
+		if limit, err := cgroups.ParseMemory(res.MemoryLimit); err == nil {
+			r.Memory = &oci.LinuxMemory{Limit: &limit}
+		}
+	}
+	if res.Cpus != "" || res.CpusetCpus != "" || res.CpuShares != 0 {
+		r.CPU = &oci.LinuxCPU{Cpus: res.CpusetCpus}
+		if res.CpuShares != 0 {
+			shares := res.CpuShares
+			r.CPU.Shares = &shares
+		}
+		if quota, period, err := cgroups.ParseCpus(res.Cpus); res.Cpus != "" && err == nil {
+			p := uint64(period)
+			r.CPU.Quota, r.CPU.Period = &quota, &p
+		}
+	}
+	if res.PidsLimit != 0 {
+		r.Pids = &oci.LinuxPids{Limit: res.PidsLimit}
+	}
+	return r
+}
@@ -0,0 +1,63 @@
+package command
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"yocker/cgroups"
+	"yocker/container"
+)
+
+var PauseCommand = &cli.Command{
+	Name:  "pause",
+	Usage: "暂停容器中的所有进程，yocker pause [container...]",
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少容器名")
+			return errors.New("缺少容器名")
+		}
+		for _, containerName := range context.Args().Slice() {
+			if err := freezeContainer(containerName, container.OpPause); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var UnpauseCommand = &cli.Command{
+	Name:  "unpause",
+	Usage: "恢复被暂停的容器，yocker unpause [container...]",
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少容器名")
+			return errors.New("缺少容器名")
+		}
+		for _, containerName := range context.Args().Slice() {
+			if err := freezeContainer(containerName, container.OpUnpause); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// op为OpPause时冻结容器的cgroup OpUnpause时解冻
+func freezeContainer(containerName string, op container.Operation) error {
+	containerInfo, err := container.GetContainerInfoByName(containerName)
+	if err != nil {
+		logrus.Errorf("获取容器信息失败 %s %v", containerName, err)
+		return err
+	}
+	_, err = container.ModifyContainerInfo(containerInfo.Name, func(containerInfo *container.ContainerInfo) error {
+		if err := containerInfo.Transition(op); err != nil {
+			return err
+		}
+		return cgroups.NewCgroupManager(containerInfo.Id).Freeze(op == container.OpPause)
+	})
+	if err != nil {
+		logrus.Errorf("%s 容器失败 %s %v", op, containerName, err)
+		return err
+	}
+	return nil
+}
@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
-	"os"
+	"strings"
+	"time"
+	"yocker/cgroups"
 	"yocker/container"
+	"yocker/fs"
+	"yocker/network"
 )
 
 var RemoveCommand = &cli.Command{
@@ -29,13 +33,86 @@ func removeContainer(containerName string) {
 		logrus.Errorf("获取容器信息失败 %s %v", containerName, err)
 		return
 	}
+	status := containerInfo.Status
+	if err := containerInfo.Transition(container.OpRemove); err != nil {
+		logrus.Errorf("删除容器失败 %v", err)
+		return
+	}
+	// created状态的容器进程还在 通知监控进程结束它
+	if status == container.Created {
+		if err := container.CancelStart(containerName); err != nil {
+			logrus.Warnf("取消启动容器失败 %v", err)
+		}
+		if !container.WaitForExit(containerInfo, 5*time.Second) {
+			logrus.Errorf("等待容器进程退出超时 %s", containerName)
+			return
+		}
+	}
+	if err := cleanupContainer(containerInfo); err != nil {
+		logrus.Errorf("删除容器失败 %s %v", containerName, err)
+	}
+}
+
+// 删除已经退出的容器的读写层 网络 cgroup和容器信息
+// stop时已经执行过poststop hook 其他状态的容器在删除后执行
+// 某一步失败时容器标记为dead 保留容器信息 之后可以再次rm清理剩下的资源
+func cleanupContainer(containerInfo *container.ContainerInfo) error {
+	var failed []string
+	// bundle的rootfs不属于yocker 不能删除
+	if containerInfo.Bundle == "" {
+		if err := fs.DeleteWorkSpace(containerInfo.Name, containerInfo.Volumes); err != nil {
+			failed = append(failed, fmt.Sprintf("删除容器读写层失败 %v", err))
+		}
+	}
+	networkReleased := false
+	if containerInfo.NetworkName != "" && containerInfo.IPAddress != "" {
+		network.Init()
+		if err := network.Disconnect(containerInfo.NetworkName, containerInfo); err != nil {
+			failed = append(failed, fmt.Sprintf("清理容器网络失败 %v", err))
+		} else {
+			networkReleased = true
+		}
+	}
+	if err := cgroups.NewCgroupManager(containerInfo.Id).Destroy(); err != nil {
+		failed = append(failed, fmt.Sprintf("删除容器cgroup失败 %v", err))
+	}
+	if len(failed) > 0 {
+		markContainerDead(containerInfo.Name, networkReleased)
+		return errors.New(strings.Join(failed, " "))
+	}
+	if err := container.DeleteContainerInfo(containerInfo.Name); err != nil {
+		return err
+	}
 	if containerInfo.Status != container.Stop {
-		logrus.Errorf("无法删除非停止状态的容器")
+		runPoststopHooks(containerInfo)
+	}
+	return nil
+}
+
+// 已经释放的ip不再记录 再次rm时不会重复释放
+func markContainerDead(containerName string, networkReleased bool) {
+	_, err := container.ModifyContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if networkReleased {
+			containerInfo.IPAddress = ""
+		}
+		return containerInfo.Transition(container.OpDie)
+	})
+	if err != nil {
+		logrus.Errorf("标记容器为dead失败 %s %v", containerName, err)
+	}
+}
+
+// 指定了--rm的容器退出后自动删除 是否删除以容器信息为准 restart期间会临时取消自动删除
+// 容器已经被rm或者又被启动时跳过
+func autoRemoveContainer(containerName string) {
+	containerInfo, err := container.GetContainerInfoByName(containerName)
+	if err != nil || !containerInfo.AutoRemove {
 		return
 	}
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	if err := os.RemoveAll(dirURL); err != nil{
-		logrus.Errorf("删除容器失败 %s %v", dirURL, err)
+	if containerInfo.Status != container.Exit && containerInfo.Status != container.Stop {
 		return
 	}
+	if err := cleanupContainer(containerInfo); err != nil {
+		logrus.Errorf("自动删除容器失败 %s %v", containerName, err)
+	}
 }
@@ -0,0 +1,98 @@
+package command
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"time"
+	"yocker/container"
+)
+
+var RestartCommand = &cli.Command{
+	Name:  "restart",
+	Usage: "重启容器，yocker restart [-t 10] [container...]",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:    "time",
+			Aliases: []string{"t"},
+			Usage:   "等待容器退出的秒数 超时后强制结束容器",
+			Value:   defaultStopTimeout,
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少容器名")
+			return errors.New("缺少容器名")
+		}
+		timeout := time.Duration(context.Int("time")) * time.Second
+		for _, containerName := range context.Args().Slice() {
+			if err := restartContainer(containerName, timeout); err != nil {
+				logrus.Errorf("重启容器失败 %s %v", containerName, err)
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func restartContainer(containerName string, timeout time.Duration) error {
+	containerInfo, err := container.GetContainerInfoByName(containerName)
+	if err != nil {
+		return err
+	}
+	if err := containerInfo.CanTransition(container.OpRestart); err != nil {
+		return err
+	}
+	if containerInfo.Status == container.Running || containerInfo.Status == container.Paused {
+		// 指定了--rm的容器stop后会被监控进程删除 重启期间先取消自动删除
+		autoRemove := containerInfo.AutoRemove
+		if autoRemove {
+			_, err := container.ModifyContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+				containerInfo.AutoRemove = false
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := stopContainer(containerName, timeout); err != nil {
+			return err
+		}
+		if containerInfo, err = container.GetContainerInfoByName(containerName); err != nil {
+			return err
+		}
+		containerInfo.AutoRemove = autoRemove
+	}
+	return relaunchWithMonitor(containerInfo)
+}
+
+// 按照容器记录的参数重新创建容器进程 复用容器的读写层 cgroup和网络配置
+func relaunchWithMonitor(containerInfo *container.ContainerInfo) error {
+	opts := &RunOptions{
+		ContainerId:   containerInfo.Id,
+		ContainerName: containerInfo.Name,
+		Cmd:           containerInfo.Command,
+		Volumes:       containerInfo.Volumes,
+		ImageName:     containerInfo.ImageName,
+		ImageID:       containerInfo.ImageID,
+		Env:           containerInfo.Env,
+		NetworkName:   containerInfo.NetworkName,
+		PortMapping:   containerInfo.PortMapping,
+		Resource:      containerInfo.Resource,
+		RestartPolicy: containerInfo.RestartPolicy,
+		StopSignal:    containerInfo.StopSignal,
+		AutoRemove:    containerInfo.AutoRemove,
+		Init:          containerInfo.Init,
+		User:          containerInfo.User,
+		WorkingDir:    containerInfo.WorkingDir,
+		Hostname:      containerInfo.Hostname,
+		Hooks:         containerInfo.Hooks,
+		Restart:       true,
+	}
+	if containerInfo.Bundle != "" {
+		if err := applyBundle(opts, containerInfo.Bundle); err != nil {
+			return err
+		}
+	}
+	return startMonitor(opts)
+}
@@ -0,0 +1,113 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"path/filepath"
+	"strings"
+	"yocker/container"
+	"yocker/image"
+)
+
+var RemoveImageCommand = &cli.Command{
+	Name:  "rmi",
+	Usage: "删除镜像，yocker rmi [-f] image...",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "强制删除已退出的容器仍在使用的镜像 以及有多个tag的镜像",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少镜像名")
+			return errors.New("缺少镜像名")
+		}
+		var failed bool
+		for _, ref := range context.Args().Slice() {
+			if err := removeImage(ref, context.Bool("force")); err != nil {
+				logrus.Errorf("删除镜像 %s 失败 %v", ref, err)
+				failed = true
+			}
+		}
+		if failed {
+			return errors.New("删除镜像失败")
+		}
+		return nil
+	},
+}
+
+// 按tag删除时如果镜像还有其他tag只去掉这个tag 否则删除镜像
+func removeImage(ref string, force bool) error {
+	// 读取容器信息时可能会更新镜像索引 要在加锁前读取
+	containers, err := imageUsers()
+	if err != nil {
+		return err
+	}
+	return image.Update(func(idx *image.Index) error {
+		img, err := idx.Lookup(ref)
+		if err != nil {
+			return err
+		}
+		byTag := img.HasTag(ref)
+		if byTag && len(img.RepoTags) > 1 {
+			idx.Untag(img, ref)
+			normalized, _ := image.NormalizeReference(ref)
+			fmt.Printf("Untagged: %s\n", normalized)
+			return nil
+		}
+		// 和docker一致 按id删除有多个tag的镜像需要--force
+		if !byTag && len(img.RepoTags) > 1 && !force {
+			return fmt.Errorf("镜像有多个tag %s 使用--force删除", strings.Join(img.RepoTags, " "))
+		}
+		if err := checkImageUsers(containers, img, force); err != nil {
+			return err
+		}
+		tags := img.RepoTags
+		if err := idx.Remove(img); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			fmt.Printf("Untagged: %s\n", tag)
+		}
+		fmt.Printf("Deleted: %s\n", img.ID)
+		return nil
+	})
+}
+
+// 读取/var/run/yocker下所有使用镜像的容器
+func imageUsers() ([]*container.ContainerInfo, error) {
+	configs, err := filepath.Glob(fmt.Sprintf(container.DefaultInfoLocation, "*") + container.ConfigName)
+	if err != nil {
+		return nil, err
+	}
+	var containers []*container.ContainerInfo
+	for _, config := range configs {
+		containerInfo, err := container.GetContainerInfoByName(filepath.Base(filepath.Dir(config)))
+		if err != nil || containerInfo.ImageID == "" {
+			continue
+		}
+		containers = append(containers, containerInfo)
+	}
+	return containers, nil
+}
+
+// 未退出的容器使用的镜像即使--force也不能删除
+func checkImageUsers(containers []*container.ContainerInfo, img *image.Image, force bool) error {
+	for _, containerInfo := range containers {
+		if containerInfo.ImageID != img.ID {
+			continue
+		}
+		if containerInfo.Status != container.Exit && containerInfo.Status != container.Stop {
+			return fmt.Errorf("镜像正在被%s的容器 %s 使用", containerInfo.Status, containerInfo.Name)
+		}
+		if !force {
+			return fmt.Errorf("镜像正在被容器 %s 使用 使用--force删除", containerInfo.Name)
+		}
+		logrus.Warnf("容器 %s 使用的镜像被删除 之后无法重启", containerInfo.Name)
+	}
+	return nil
+}
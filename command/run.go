@@ -1,64 +1,41 @@
 package command
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"os"
 	"os/exec"
-	"strings"
 	"syscall"
+	"yocker/cgroups"
 	"yocker/container"
 	"yocker/fs"
+	"yocker/image"
 	"yocker/network"
+	"yocker/oci"
 )
 
 var RunCommand = &cli.Command{
 	Name:  "run",
 	Usage: "在限制命名空间和cgroup的情况下创建一个容器，yocker run -ti [command]",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.BoolFlag{
 			Name:  "ti",
 			Usage: "是否启用终端",
 		},
-		&cli.StringFlag{
-			Name:  "v",
-			Usage: "volume挂载",
-		},
 		&cli.BoolFlag{
 			Name:  "d",
 			Usage: "后台运行容器",
 		},
-		&cli.StringFlag{
-			Name:  "name",
-			Usage: "容器名",
-		},
-		&cli.StringFlag{
-			Name:  "image",
-			Usage: "运行的镜像名",
-		},
-		&cli.StringSliceFlag{
-			Name:  "e",
-			Usage: "容器运行的环境变量",
-		},
-		&cli.StringFlag{
-			Name:  "net",
-			Usage: "容器要加入的网络",
-		},
-		&cli.StringSliceFlag{
-			Name:  "p",
-			Usage: "端口映射",
-		},
-	},
+	}, containerFlags...),
 	Action: func(context *cli.Context) error {
-		if context.NArg() < 1 {
+		if context.NArg() < 1 && context.String("bundle") == "" {
 			logrus.Errorf("缺少启动命令或镜像名")
 			return errors.New("缺少启动命令或镜像名")
 		}
 
-		imageName := context.String("image")
-
 		tty := context.Bool("ti")
 		detach := context.Bool("d")
 
@@ -73,61 +50,462 @@ var RunCommand = &cli.Command{
 			tty = true
 		}
 
-		containerName := context.String("name")
-		volume := context.String("v")
-		envArr := context.StringSlice("e")
+		opts, err := parseRunOptions(context)
+		if err != nil {
+			return err
+		}
+		if tty && opts.RestartPolicy.Name != container.RestartNo {
+			logrus.Errorf("终端运行的容器不支持重启策略")
+			return fmt.Errorf("终端运行的容器不支持重启策略")
+		}
+		opts.Tty = tty
+		Run(opts)
+		return nil
+	},
+}
 
-		networkName := context.String("net")
-		portMapping := context.StringSlice("p")
+// run和create共用的容器参数
+var containerFlags = append([]cli.Flag{
+	&cli.StringSliceFlag{
+		Name:  "v",
+		Usage: "volume挂载 host:container 可以指定多个",
+	},
+	&cli.StringFlag{
+		Name:  "name",
+		Usage: "容器名",
+	},
+	&cli.StringFlag{
+		Name:  "image",
+		Usage: "运行的镜像名",
+	},
+	&cli.StringSliceFlag{
+		Name:  "e",
+		Usage: "容器运行的环境变量",
+	},
+	&cli.StringFlag{
+		Name:  "net",
+		Usage: "容器要加入的网络",
+	},
+	&cli.StringSliceFlag{
+		Name:  "p",
+		Usage: "端口映射",
+	},
+	&cli.StringFlag{
+		Name:  "stop-signal",
+		Usage: "stop时发送给容器的信号",
+		Value: container.DefaultStopSignal,
+	},
+	&cli.StringFlag{
+		Name:  "restart",
+		Usage: "容器退出后的重启策略 no on-failure[:N] always unless-stopped",
+		Value: container.RestartNo,
+	},
+	&cli.BoolFlag{
+		Name:  "rm",
+		Usage: "容器退出后删除容器的读写层 网络和容器信息",
+	},
+	&cli.BoolFlag{
+		Name:  "init",
+		Usage: "由yocker init作为容器的1号进程 转发信号并回收僵尸进程",
+	},
+	&cli.StringFlag{
+		Name:    "user",
+		Aliases: []string{"u"},
+		Usage:   "运行用户命令的用户 uid[:gid]",
+	},
+	&cli.StringFlag{
+		Name:    "workdir",
+		Aliases: []string{"w"},
+		Usage:   "用户命令在容器中的工作目录",
+	},
+	&cli.StringFlag{
+		Name:  "hostname",
+		Usage: "容器的主机名 默认为容器名",
+	},
+	&cli.StringFlag{
+		Name:  "bundle",
+		Usage: "OCI bundle目录 按照其中的config.json创建容器",
+	},
+	&cli.StringFlag{
+		Name:  "hooks",
+		Usage: "hook配置文件 格式和OCI spec中的hooks相同",
+	},
+}, resourceFlags...)
 
-		Run(context.Args().Slice(), tty, volume, containerName, imageName, envArr, networkName, portMapping)
-		return nil
+func parseRunOptions(context *cli.Context) (*RunOptions, error) {
+	restartPolicy, err := container.ParseRestartPolicy(context.String("restart"))
+	if err != nil {
+		logrus.Errorf("解析重启策略失败 %v", err)
+		return nil, err
+	}
+	autoRemove := context.Bool("rm")
+	if autoRemove && restartPolicy.Name != container.RestartNo {
+		logrus.Errorf("--rm和重启策略不能同时使用")
+		return nil, fmt.Errorf("--rm和重启策略不能同时使用")
+	}
+	stopSignal := context.String("stop-signal")
+	if _, err := container.ParseSignal(stopSignal); err != nil {
+		logrus.Errorf("解析stop-signal失败 %v", err)
+		return nil, err
+	}
+	user := context.String("user")
+	if user != "" {
+		if _, _, err := container.ParseUser(user); err != nil {
+			logrus.Errorf("解析用户失败 %v", err)
+			return nil, err
+		}
+	}
+	opts := &RunOptions{
+		Cmd:           context.Args().Slice(),
+		StopSignal:    stopSignal,
+		Volumes:       context.StringSlice("v"),
+		ContainerName: context.String("name"),
+		ImageName:     context.String("image"),
+		Env:           context.StringSlice("e"),
+		NetworkName:   context.String("net"),
+		PortMapping:   context.StringSlice("p"),
+		Resource:      parseResourceConfig(context),
+		RestartPolicy: restartPolicy,
+		AutoRemove:    autoRemove,
+		Init:          context.Bool("init"),
+		User:          user,
+		WorkingDir:    context.String("workdir"),
+		Hostname:      context.String("hostname"),
+	}
+	if hooksPath := context.String("hooks"); hooksPath != "" {
+		if opts.Hooks, err = loadHooks(hooksPath); err != nil {
+			logrus.Errorf("读取hooks失败 %v", err)
+			return nil, err
+		}
+	}
+	if bundle := context.String("bundle"); bundle != "" {
+		if err := applyBundle(opts, bundle); err != nil {
+			logrus.Errorf("读取bundle失败 %v", err)
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// run update create共用的资源限制参数
+var resourceFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "memory",
+		Aliases: []string{"m"},
+		Usage:   "内存限制 如100m",
 	},
+	&cli.StringFlag{
+		Name:  "cpus",
+		Usage: "可使用的cpu核数 如0.5",
+	},
+	&cli.StringFlag{
+		Name:  "cpuset-cpus",
+		Usage: "可使用的cpu 如0-2",
+	},
+	&cli.Uint64Flag{
+		Name:  "cpu-shares",
+		Usage: "cpu权重 默认1024",
+	},
+	&cli.Int64Flag{
+		Name:  "pids-limit",
+		Usage: "最大进程数 -1为不限制",
+	},
+}
+
+func parseResourceConfig(context *cli.Context) *cgroups.ResourceConfig {
+	return &cgroups.ResourceConfig{
+		MemoryLimit: context.String("memory"),
+		Cpus:        context.String("cpus"),
+		CpusetCpus:  context.String("cpuset-cpus"),
+		CpuShares:   context.Uint64("cpu-shares"),
+		PidsLimit:   context.Int64("pids-limit"),
+	}
 }
 
-func Run(cmdArr []string, tty bool, volume, containerName, imageName string, envArr []string, networkName string, portMapping []string) {
+// RunOptions 创建容器需要的参数 后台运行时会序列化后传给监控进程
+type RunOptions struct {
+	ContainerId   string   `json:"container_id"`
+	ContainerName string   `json:"container_name"`
+	Cmd           []string `json:"cmd"`
+	Tty           bool     `json:"tty"`
+	Volumes       []string `json:"volumes"`
+	ImageName     string   `json:"image_name"`
+	// 镜像的id 不为空时按id查找镜像 重启时使用创建容器时的镜像
+	ImageID string `json:"image_id"`
+	// 镜像所有layer的目录 作为容器overlay的lowerdir
+	LowerDir    string                  `json:"lower_dir"`
+	Env         []string                `json:"env"`
+	NetworkName string                  `json:"network_name"`
+	PortMapping []string                `json:"port_mapping"`
+	Resource    *cgroups.ResourceConfig `json:"resource"`
+	// 为true时只创建容器 等到start命令后才运行用户命令
+	WaitStart bool `json:"wait_start"`
+	// 重启策略
+	RestartPolicy *container.RestartPolicy `json:"restart_policy"`
+	// stop时发送给容器的信号
+	StopSignal string `json:"stop_signal"`
+	// 为true时重新启动已经存在的容器 复用容器信息 读写层和cgroup
+	Restart bool `json:"restart"`
+	// 容器退出后自动删除
+	AutoRemove bool `json:"auto_remove"`
+	// 由yocker init作为容器的1号进程运行用户命令
+	Init bool `json:"init"`
+	// 运行用户命令的用户 工作目录和容器的主机名
+	User       string `json:"user"`
+	WorkingDir string `json:"working_dir"`
+	Hostname   string `json:"hostname"`
+	// 以下参数来自OCI bundle 为空时使用镜像的overlay作为rootfs和默认的挂载与namespace
+	Bundle         string            `json:"bundle"`
+	Rootfs         string            `json:"rootfs"`
+	ReadonlyRootfs bool              `json:"readonly_rootfs"`
+	Mounts         []container.Mount `json:"mounts"`
+	Namespaces     []string          `json:"namespaces"`
+	// 容器生命周期中执行的hook
+	Hooks *oci.Hooks `json:"hooks"`
+}
+
+func Run(opts *RunOptions) {
+	opts.ContainerId = container.NewContainerId()
+	if opts.ContainerName == "" {
+		opts.ContainerName = opts.ContainerId
+	}
+	// 后台运行的容器交给监控进程启动 监控进程作为容器的父进程等待容器退出
+	if !opts.Tty {
+		if err := startMonitor(opts); err != nil {
+			logrus.Errorf("启动容器失败 %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	c, err := createContainer(opts)
+	if err != nil {
+		logrus.Errorf("创建容器失败 %v", err)
+		os.Exit(1)
+	}
+	if err := c.start(); err != nil {
+		logrus.Errorf("启动容器失败 %v", err)
+		os.Exit(1)
+	}
+	// 容器进程非0退出时Wait也会返回错误 退出状态从ProcessState中获取
+	c.parent.Wait()
+	status := c.parent.ProcessState.Sys().(syscall.WaitStatus)
+	if err := container.RecordContainerExit(opts.ContainerName, c.parent.Process.Pid, status); err != nil {
+		logrus.Errorf("记录容器退出状态失败 %v", err)
+	}
+	// 没有指定--rm时保留exited状态的容器 之后可以查看 commit或者重启
+	autoRemoveContainer(opts.ContainerName)
+	os.Exit(container.ExitCode(status))
+}
+
+// 已经创建好的容器 容器进程阻塞在管道上等待用户命令
+type createdContainer struct {
+	parent        *exec.Cmd
+	writePipe     *os.File
+	cgroupManager cgroups.Manager
+	info          *container.ContainerInfo
+	opts          *RunOptions
+	// start时通过writePipe发送给init进程
+	initConfig *container.InitConfig
+}
+
+// 创建容器进程 设置cgroup 记录容器信息 配置网络 容器处于created状态
+func createContainer(opts *RunOptions) (*createdContainer, error) {
+	// 和docker一致 容器名不能重复 退出的容器也会保留到rm为止
+	if !opts.Restart && container.ContainerExists(opts.ContainerName) {
+		return nil, fmt.Errorf("容器名 %s 已经被使用", opts.ContainerName)
+	}
+	// bundle运行的容器使用bundle中的rootfs 不需要镜像
+	if opts.Rootfs == "" {
+		if err := resolveImage(opts); err != nil {
+			return nil, err
+		}
+	}
 	// 先启动一个父进程
-	parent, writePipe := NewParentProcess(tty, volume, containerName, imageName, envArr)
+	parent, writePipe := NewParentProcess(opts)
+	c := &createdContainer{
+		parent:        parent,
+		writePipe:     writePipe,
+		cgroupManager: cgroups.NewCgroupManager(opts.ContainerId),
+		opts:          opts,
+		initConfig:    newInitConfig(opts),
+	}
+	// 之后任何一步失败都撤销已经完成的步骤 不留下半创建的容器
+	created := false
+	defer func() {
+		if !created {
+			c.rollback()
+		}
+	}()
 	if parent == nil {
-		logrus.Errorf("创建父进程失败")
-		return
+		return nil, errors.New("创建父进程失败")
 	}
 	if err := parent.Start(); err != nil {
-		logrus.Error(err)
+		return nil, fmt.Errorf("启动父进程失败 %v", err)
 	}
 
-	containerInfo, err := container.RecordContainerInfo(parent.Process.Pid, cmdArr, containerName, volume)
-	if err != nil {
-		logrus.Errorf("记录容器信息失败 %v", err)
-		return
+	// 在发送init命令前把容器进程加入cgroup 用户进程启动后就已经受到限制
+	cgroupManager := c.cgroupManager
+	if err := cgroupManager.Set(opts.Resource); err != nil {
+		return nil, fmt.Errorf("设置资源限制失败 %v", err)
+	}
+	// 暂停的容器退出后cgroup仍处于冻结状态 重启时先解冻 否则新的进程加入后也会被冻结
+	if opts.Restart {
+		if err := cgroupManager.Freeze(false); err != nil {
+			logrus.Warnf("解冻容器cgroup失败 %v", err)
+		}
+	}
+	if err := cgroupManager.Apply(parent.Process.Pid); err != nil {
+		return nil, fmt.Errorf("把容器进程加入cgroup失败 %v", err)
 	}
 
-	if networkName != "" {
-		network.Init()
-		containerInfo.PortMapping = portMapping
-		if err := network.Connect(networkName, containerInfo); err != nil {
-			logrus.Errorf("加入网络失败")
-			return
+	var containerInfo *container.ContainerInfo
+	var err error
+	if opts.Restart {
+		containerInfo, err = container.RecordContainerRestart(parent.Process.Pid, opts.ContainerName)
+	} else {
+		containerInfo, err = container.RecordContainerInfo(parent.Process.Pid, opts.Cmd, opts.ContainerId, opts.ContainerName, opts.LowerDir, opts.Volumes, opts.Resource)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("记录容器信息失败 %v", err)
+	}
+	containerInfo, err = container.ModifyContainerInfo(opts.ContainerName, func(containerInfo *container.ContainerInfo) error {
+		containerInfo.PortMapping = opts.PortMapping
+		containerInfo.RestartPolicy = opts.RestartPolicy
+		containerInfo.ImageName = opts.ImageName
+		containerInfo.ImageID = opts.ImageID
+		containerInfo.Env = opts.Env
+		containerInfo.NetworkName = opts.NetworkName
+		containerInfo.StopSignal = opts.StopSignal
+		containerInfo.AutoRemove = opts.AutoRemove
+		containerInfo.Init = opts.Init
+		containerInfo.User = opts.User
+		containerInfo.WorkingDir = opts.WorkingDir
+		containerInfo.Hostname = opts.Hostname
+		containerInfo.Bundle = opts.Bundle
+		containerInfo.Rootfs = opts.Rootfs
+		containerInfo.Hooks = opts.Hooks
+		if opts.NetworkName != "" {
+			network.Init()
+			if err := network.Connect(opts.NetworkName, containerInfo); err != nil {
+				// 容器信息没有写回 已经分配的ip和创建的veth在这里释放
+				if containerInfo.IPAddress != "" {
+					network.Disconnect(opts.NetworkName, containerInfo)
+				}
+				return fmt.Errorf("加入网络失败 %v", err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("记录容器信息失败 %v", err)
+	}
+	c.info = containerInfo
+	// 容器的namespace和cgroup已经创建好 用户命令还没有运行
+	for _, name := range []string{oci.HookPrestart, oci.HookCreateRuntime} {
+		if err := runContainerHooks(containerInfo, name, oci.StateCreating); err != nil {
+			return nil, err
+		}
+	}
+	created = true
+	return c, nil
+}
+
+// 把容器状态改为running 然后发送启动信息让容器开始运行
+func (c *createdContainer) start() error {
+	// created状态下容器信息可能被update修改过 加锁后重新读取
+	containerInfo, err := container.ModifyContainerInfo(c.info.Name, func(containerInfo *container.ContainerInfo) error {
+		return containerInfo.Transition(container.OpStart)
+	})
+	if err != nil {
+		c.rollback()
+		return err
+	}
+	c.info = containerInfo
+	if err := sendInitConfig(c.initConfig, c.writePipe); err != nil {
+		c.rollback()
+		return err
+	}
+	// 用户命令已经开始运行 poststart失败不影响容器
+	if err := runContainerHooks(c.info, oci.HookPoststart, oci.StateRunning); err != nil {
+		logrus.Warnf("%v", err)
 	}
+	return nil
+}
 
-	// 发送init命令
-	sendInitCommand(cmdArr, writePipe)
-	if tty {
-		parent.Wait()
-		//mntURL := "/opt/yocker/yocker/merged/"
-		//rootURL := "/opt/yocker/yocker/"
-		fs.DeleteWorkSpace(containerName, volume)
-		err := container.DeleteContainerInfo(containerName, volume)
+// 创建或启动容器失败时结束容器进程 和--rm一样清理cgroup 网络 读写层和容器信息
+// 重启的容器保留读写层和容器信息 只记录为没有进程
+func (c *createdContainer) rollback() {
+	name := c.opts.ContainerName
+	if c.parent != nil && c.parent.Process != nil {
+		c.parent.Process.Kill()
+		c.parent.Wait()
+	}
+	if c.writePipe != nil {
+		c.writePipe.Close()
+	}
+	if err := c.cgroupManager.Destroy(); err != nil {
+		logrus.Warnf("删除容器cgroup失败 %s %v", name, err)
+	}
+	if container.ContainerExists(name) {
+		_, err := container.ModifyContainerInfo(name, func(containerInfo *container.ContainerInfo) error {
+			if containerInfo.NetworkName != "" && containerInfo.IPAddress != "" {
+				network.Init()
+				if err := network.Disconnect(containerInfo.NetworkName, containerInfo); err != nil {
+					logrus.Warnf("清理容器网络失败 %s %v", name, err)
+				}
+			}
+			if containerInfo.Status == container.Stop || containerInfo.Status == container.Exit {
+				containerInfo.Pid = " "
+			}
+			return nil
+		})
 		if err != nil {
-			logrus.Errorf("删除容器信息失败 %v", err)
-			return
+			logrus.Warnf("更新容器信息失败 %s %v", name, err)
 		}
 	}
-	os.Exit(0)
+	if c.opts.Restart {
+		// 已经改为running的容器进程不存在了 同步为exited
+		container.GetContainerInfoByName(name)
+		return
+	}
+	if c.opts.Rootfs == "" {
+		if err := fs.DeleteWorkSpace(name, c.opts.Volumes); err != nil {
+			logrus.Warnf("删除容器读写层失败 %s %v", name, err)
+		}
+	}
+	container.DeleteContainerInfo(name)
+}
+
+// 根据容器参数生成发送给init进程的启动信息
+func newInitConfig(opts *RunOptions) *container.InitConfig {
+	// 没有uts namespace时不能设置主机名 否则会修改宿主机的主机名
+	hostname := opts.Hostname
+	if hostname == "" && hasNamespace(opts, oci.UTSNamespace) {
+		hostname = opts.ContainerName
+	}
+	// bundle运行的容器只使用spec中的环境变量
+	env := append(os.Environ(), opts.Env...)
+	if opts.Bundle != "" {
+		env = opts.Env
+	}
+	mounts := opts.Mounts
+	if mounts == nil {
+		mounts = container.DefaultMounts()
+	}
+	return &container.InitConfig{
+		Args:           opts.Cmd,
+		Env:            env,
+		Cwd:            opts.WorkingDir,
+		User:           opts.User,
+		Hostname:       hostname,
+		Mounts:         mounts,
+		ReadonlyRootfs: opts.ReadonlyRootfs,
+		Init:           opts.Init,
+	}
 }
 
-func NewParentProcess(tty bool, volume, containerName, imageName string, envArr []string) (*exec.Cmd, *os.File) {
+func NewParentProcess(opts *RunOptions) (*exec.Cmd, *os.File) {
 	readPipe, writePipe, err := NewPipe()
 	if err != nil {
 		logrus.Errorf("创建管道失败 %v", err)
@@ -137,42 +515,69 @@ func NewParentProcess(tty bool, volume, containerName, imageName string, envArr
 	command := exec.Command("/proc/self/exe", args...)
 	//command.Dir = "/opt/yocker/yocker/busybox"
 	command.SysProcAttr = &syscall.SysProcAttr{
-		Cloneflags: syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC,
+		Cloneflags: cloneFlags(opts),
 	}
-	if tty {
+	if opts.Tty {
 		command.Stdin = os.Stdin
 		command.Stdout = os.Stdout
 		command.Stderr = os.Stderr
 	} else {
 		// 后台运行 把输出重定向到日志
-		dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+		dirURL := fmt.Sprintf(container.DefaultInfoLocation, opts.ContainerName)
 		if err := os.MkdirAll(dirURL, 0622); err != nil {
 			logrus.Errorf("创建父进程中创建容器目录失败 %s %v", dirURL, err)
 			return nil, nil
 		}
 		stdLogFilePath := dirURL + container.ContainerLogFile
-		stdLogFile, err := os.Create(stdLogFilePath)
+		// 重启后的容器接着写之前的日志
+		stdLogFile, err := os.OpenFile(stdLogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
 			logrus.Errorf("创建父进程中创建日志文件失败 %v", err)
 			return nil, nil
 		}
 		command.Stdout = stdLogFile
 	}
-	command.Env = append(os.Environ(), envArr...)
+	command.Env = append(os.Environ(), opts.Env...)
 	command.ExtraFiles = []*os.File{readPipe}
 	//mntURL := "/opt/yocker/yocker/merged/"
 	//rootURL := "/opt/yocker/yocker/"
-	fs.NewWorkSpace(imageName, containerName, volume)
-	command.Dir = fs.GetUnTar(imageName)
+	// bundle运行的容器直接使用bundle中的rootfs
+	if opts.Rootfs != "" {
+		command.Dir = opts.Rootfs
+		return command, writePipe
+	}
+	if err := fs.NewWorkSpace(opts.LowerDir, opts.ContainerName, opts.Volumes, opts.Restart); err != nil {
+		logrus.Errorf("创建容器工作目录失败 %v", err)
+		return nil, nil
+	}
+	// 容器的rootfs是overlay的merged目录 写入的内容都在容器自己的upper层
+	command.Dir = fs.GetMerged(opts.ContainerName)
 	return command, writePipe
 }
 
-func sendInitCommand(cmdArr []string, pipe *os.File) {
-	defer pipe.Close()
+// 查找并解压容器使用的镜像 记录镜像的id和解压目录
+func resolveImage(opts *RunOptions) error {
+	ref := opts.ImageName
+	if opts.ImageID != "" {
+		ref = opts.ImageID
+	}
+	img, err := image.Resolve(ref)
+	if err != nil {
+		return fmt.Errorf("获取镜像失败 %v", err)
+	}
+	opts.ImageID = img.ID
+	opts.LowerDir = img.LowerDir()
+	return nil
+}
 
-	cmd := strings.Join(cmdArr, " ")
-	logrus.Infof("用户命令是 %s", cmd)
-	pipe.WriteString(cmd)
+// 把启动信息编码为json写入管道 关闭管道后init进程才会读到完整的内容
+func sendInitConfig(config *container.InitConfig, pipe *os.File) error {
+	defer pipe.Close()
+	logrus.Infof("用户命令是 %q", config.Args)
+	if err := json.NewEncoder(pipe).Encode(config); err != nil {
+		return fmt.Errorf("发送启动信息失败 %v", err)
+	}
+	return nil
 }
 
 func NewPipe() (*os.File, *os.File, error) {
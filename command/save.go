@@ -0,0 +1,61 @@
+package command
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+	"yocker/image"
+)
+
+var SaveCommand = &cli.Command{
+	Name:  "save",
+	Usage: "把镜像保存为OCI镜像布局的tar包，yocker save [-o image.tar] image...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "写入的tar包 默认写到标准输出",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少镜像名")
+			return errors.New("缺少镜像名")
+		}
+		output := context.String("output")
+		if output == "" {
+			return saveImages(context.Args().Slice(), os.Stdout)
+		}
+		file, err := os.Create(output)
+		if err != nil {
+			logrus.Errorf("创建镜像包失败 %v", err)
+			return err
+		}
+		err = saveImages(context.Args().Slice(), file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		// 不保留写了一半的tar包
+		if err != nil {
+			os.Remove(output)
+		}
+		return err
+	},
+}
+
+func saveImages(refs []string, w io.Writer) error {
+	// 和docker一致 不把tar包输出到终端
+	if file, ok := w.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			logrus.Errorf("不能把镜像包写到终端 请使用-o或重定向标准输出")
+			return errors.New("不能把镜像包写到终端")
+		}
+	}
+	if err := image.SaveArchive(refs, w); err != nil {
+		logrus.Errorf("保存镜像失败 %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+	"time"
+	"yocker/container"
+)
+
+// 等待监控进程把容器状态改为running的超时时间
+const startTimeout = 5 * time.Second
+
+var StartCommand = &cli.Command{
+	Name:  "start",
+	Usage: "启动created stopped或exited状态的容器，yocker start [-a] [container...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "a",
+			Usage: "输出容器日志并等待容器退出 以容器的退出码退出",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少容器名")
+			return errors.New("缺少容器名")
+		}
+		attach := context.Bool("a")
+		if attach && context.NArg() > 1 {
+			logrus.Errorf("-a只能启动一个容器")
+			return errors.New("-a只能启动一个容器")
+		}
+		for _, containerName := range context.Args().Slice() {
+			if err := startContainer(containerName); err != nil {
+				logrus.Errorf("启动容器失败 %s %v", containerName, err)
+				return err
+			}
+		}
+		if attach {
+			exitCode, err := attachContainer(context.Args().First())
+			if err != nil {
+				return err
+			}
+			os.Exit(exitCode)
+		}
+		return nil
+	},
+}
+
+func startContainer(containerName string) error {
+	containerInfo, err := container.GetContainerInfoByName(containerName)
+	if err != nil {
+		return err
+	}
+	if err := containerInfo.CanTransition(container.OpStart); err != nil {
+		return err
+	}
+	// 已经停止的容器按记录的参数重新创建容器进程
+	if containerInfo.Status != container.Created {
+		return relaunchWithMonitor(containerInfo)
+	}
+	if err := container.SendStartSignal(containerName); err != nil {
+		return err
+	}
+	// 等监控进程把状态改为running后再返回
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		containerInfo, err = container.GetContainerInfoByName(containerName)
+		if err != nil {
+			return err
+		}
+		if containerInfo.Status != container.Created {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("等待容器启动超时")
+}
+
+// 持续输出后台容器的日志 直到容器退出 返回容器的退出码
+func attachContainer(containerName string) (int, error) {
+	logFilePath := fmt.Sprintf(container.DefaultInfoLocation, containerName) + container.ContainerLogFile
+	logFile, err := os.Open(logFilePath)
+	if err != nil {
+		logrus.Errorf("打开日志文件失败 %s %v", containerName, err)
+		return 0, err
+	}
+	defer logFile.Close()
+	exitFile, err := container.OpenExitCode(containerName)
+	if err != nil {
+		logrus.Errorf("%s %v", containerName, err)
+		return 0, err
+	}
+	defer exitFile.Close()
+	for {
+		if _, err := io.Copy(os.Stdout, logFile); err != nil {
+			return 0, err
+		}
+		finished, exitCode, err := containerExitStatus(containerName, exitFile)
+		if err != nil {
+			return 0, err
+		}
+		if !finished {
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		// 容器退出后把剩下的日志输出完 日志文件被删除后已经打开的文件仍然可以读取
+		io.Copy(os.Stdout, logFile)
+		return exitCode, nil
+	}
+}
@@ -0,0 +1,229 @@
+package command
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"yocker/cgroups"
+	"yocker/container"
+	"yocker/network"
+)
+
+// 两次采样的间隔 cpu使用率根据两次采样的差值计算
+const statsInterval = time.Second
+
+var StatsCommand = &cli.Command{
+	Name:  "stats",
+	Usage: "查看容器的资源使用情况，yocker stats [container...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "no-stream",
+			Usage: "只输出一次结果",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "输出格式 table或json",
+			Value: "table",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		format := context.String("format")
+		if format != "table" && format != "json" {
+			logrus.Errorf("不支持的输出格式 %s", format)
+			return fmt.Errorf("不支持的输出格式 %s", format)
+		}
+		return statsContainers(context.Args().Slice(), context.Bool("no-stream"), format)
+	},
+}
+
+// ContainerStats 一次采样得到的容器资源使用情况
+type ContainerStats struct {
+	Id            string  `json:"id"`
+	Name          string  `json:"name"`
+	CpuPercent    float64 `json:"cpu_percent"`
+	MemoryUsage   uint64  `json:"memory_usage"`
+	MemoryLimit   uint64  `json:"memory_limit"`
+	MemoryPercent float64 `json:"memory_percent"`
+	NetRxBytes    uint64  `json:"net_rx_bytes"`
+	NetTxBytes    uint64  `json:"net_tx_bytes"`
+	BlockRead     uint64  `json:"block_read"`
+	BlockWrite    uint64  `json:"block_write"`
+	Pids          uint64  `json:"pids"`
+
+	// 上次采样的cpu时间和采样时刻 用于计算cpu使用率
+	cpuUsage uint64
+	readTime time.Time
+}
+
+func statsContainers(names []string, noStream bool, format string) error {
+	memTotal := getHostMemTotal()
+	previous := make(map[string]*ContainerStats)
+	// 先采样一次 第一次输出时才能算出cpu使用率
+	for _, containerInfo := range getStatsTargets(names) {
+		if stats, err := collectStats(containerInfo, memTotal, nil); err == nil {
+			previous[containerInfo.Id] = stats
+		}
+	}
+	for {
+		time.Sleep(statsInterval)
+		var result []*ContainerStats
+		current := make(map[string]*ContainerStats)
+		for _, containerInfo := range getStatsTargets(names) {
+			stats, err := collectStats(containerInfo, memTotal, previous[containerInfo.Id])
+			if err != nil {
+				logrus.Warnf("获取容器资源使用失败 %s %v", containerInfo.Name, err)
+				continue
+			}
+			current[containerInfo.Id] = stats
+			result = append(result, stats)
+		}
+		previous = current
+
+		if !noStream && format == "table" {
+			// 清屏后把光标移到左上角 实现刷新效果
+			fmt.Print("\033[2J\033[H")
+		}
+		if err := printStats(result, format); err != nil {
+			return err
+		}
+		if noStream {
+			return nil
+		}
+	}
+}
+
+// 没有指定容器时统计所有运行中和暂停的容器
+func getStatsTargets(names []string) []*container.ContainerInfo {
+	var targets []*container.ContainerInfo
+	if len(names) == 0 {
+		containers, err := container.ListContainers()
+		if err != nil {
+			return nil
+		}
+		for _, containerInfo := range containers {
+			if containerInfo.Status == container.Running || containerInfo.Status == container.Paused {
+				targets = append(targets, containerInfo)
+			}
+		}
+		return targets
+	}
+	for _, name := range names {
+		containerInfo, err := container.GetContainerInfoByName(name)
+		if err != nil {
+			continue
+		}
+		targets = append(targets, containerInfo)
+	}
+	return targets
+}
+
+func collectStats(containerInfo *container.ContainerInfo, memTotal uint64, previous *ContainerStats) (*ContainerStats, error) {
+	cgroupStats, err := cgroups.NewCgroupManager(containerInfo.Id).GetStats()
+	if err != nil {
+		return nil, err
+	}
+	stats := &ContainerStats{
+		Id:          containerInfo.Id,
+		Name:        containerInfo.Name,
+		MemoryUsage: cgroupStats.MemoryUsage,
+		MemoryLimit: cgroupStats.MemoryLimit,
+		BlockRead:   cgroupStats.IoReadBytes,
+		BlockWrite:  cgroupStats.IoWriteBytes,
+		Pids:        cgroupStats.Pids,
+		cpuUsage:    cgroupStats.CpuUsage,
+		readTime:    time.Now(),
+	}
+	// 没有内存限制时v2为0 v1是一个极大值 都按宿主机内存计算
+	if stats.MemoryLimit == 0 || (memTotal > 0 && stats.MemoryLimit > memTotal) {
+		stats.MemoryLimit = memTotal
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
+	if previous != nil && stats.cpuUsage >= previous.cpuUsage {
+		elapsed := stats.readTime.Sub(previous.readTime).Nanoseconds()
+		if elapsed > 0 {
+			stats.CpuPercent = float64(stats.cpuUsage-previous.cpuUsage) / float64(elapsed) * 100
+		}
+	}
+	// 没有加入网络的容器没有veth
+	if rx, tx, err := network.GetEndpointStatistics(containerInfo.Id); err == nil {
+		stats.NetRxBytes, stats.NetTxBytes = rx, tx
+	}
+	return stats, nil
+}
+
+func printStats(result []*ContainerStats, format string) error {
+	if format == "json" {
+		if result == nil {
+			result = []*ContainerStats{}
+		}
+		content, err := json.Marshal(result)
+		if err != nil {
+			logrus.Errorf("序列化资源使用情况失败 %v", err)
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "ID\tNAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O\tPIDS\n")
+	for _, item := range result {
+		fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%s / %s\t%d\n",
+			item.Id,
+			item.Name,
+			item.CpuPercent,
+			formatBytes(item.MemoryUsage), formatBytes(item.MemoryLimit),
+			item.MemoryPercent,
+			formatBytes(item.NetRxBytes), formatBytes(item.NetTxBytes),
+			formatBytes(item.BlockRead), formatBytes(item.BlockWrite),
+			item.Pids)
+	}
+	if err := w.Flush(); err != nil {
+		logrus.Errorf("flush失败 %v", err)
+		return err
+	}
+	return nil
+}
+
+func formatBytes(size uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", size, units[i])
+	}
+	return fmt.Sprintf("%.2f%s", value, units[i])
+}
+
+// 从/proc/meminfo中读取宿主机总内存 MemTotal:  16309556 kB
+func getHostMemTotal() uint64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			total, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return total * 1024
+		}
+	}
+	return 0
+}
@@ -1,56 +1,169 @@
 package command
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
-	"io/ioutil"
 	"strconv"
 	"syscall"
+	"time"
+	"yocker/cgroups"
 	"yocker/container"
+	"yocker/network"
+)
+
+const (
+	// 默认等待容器退出的时间
+	defaultStopTimeout = 10
+	// 发送SIGKILL后等待容器退出的时间
+	killTimeout = 5 * time.Second
+	// 容器进程退出后等待监控进程记录退出状态的时间
+	monitorRecordTimeout = time.Second
 )
 
 var StopCommand = &cli.Command{
 	Name:  "stop",
-	Usage: "停止容器",
+	Usage: "停止容器，yocker stop [-t 10] [container...]",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:    "time",
+			Aliases: []string{"t"},
+			Usage:   "等待容器退出的秒数 超时后发送SIGKILL",
+			Value:   defaultStopTimeout,
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if context.NArg() < 1 {
 			logrus.Errorf("缺少容器名")
 			return errors.New("缺少容器名")
 		}
-		containerName := context.Args().Get(0)
-		stopContainer(containerName)
+		timeout := time.Duration(context.Int("time")) * time.Second
+		for _, containerName := range context.Args().Slice() {
+			if err := stopContainer(containerName, timeout); err != nil {
+				return err
+			}
+		}
 		return nil
 	},
 }
 
-func stopContainer(containerName string) {
+// 先发送stop-signal 超时后发送SIGKILL 容器进程真正退出后再修改状态并清理网络
+func stopContainer(containerName string, timeout time.Duration) error {
 	containerInfo, err := container.GetContainerInfoByName(containerName)
 	if err != nil {
 		logrus.Errorf("获取容器信息失败 %s %v", containerName, err)
-		return
+		return err
+	}
+	// 先标记正在停止 监控进程记录退出状态时就不会按重启策略重启
+	containerInfo, err = container.ModifyContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if err := containerInfo.CanTransition(container.OpStop); err != nil {
+			return err
+		}
+		if containerInfo.Status != container.Exit {
+			containerInfo.StopRequested = true
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("停止容器失败 %v", err)
+		return err
+	}
+	// 已经退出的容器没有进程 监控进程不会再按重启策略重启它
+	if containerInfo.Status == container.Exit {
+		return stoppedContainer(containerName)
 	}
 	pidInt, err := strconv.Atoi(containerInfo.Pid)
 	if err != nil {
 		logrus.Errorf("转化容器的pid失败 %v", err)
-		return
+		return err
 	}
-	if err := syscall.Kill(pidInt, syscall.SIGTERM); err != nil {
+
+	// 被冻结的进程解冻后才会处理信号 发送信号后再解冻 状态按状态转移表恢复为running
+	// 之后由监控进程或者stop把状态改为stopped
+	stopSignal := containerInfo.GetStopSignal()
+	_, err = container.ModifyContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if err := syscall.Kill(pidInt, stopSignal); err != nil {
+			return err
+		}
+		if containerInfo.Status != container.Paused {
+			return nil
+		}
+		if err := cgroups.NewCgroupManager(containerInfo.Id).Freeze(false); err != nil {
+			return fmt.Errorf("解冻容器失败 %v", err)
+		}
+		return containerInfo.Transition(container.OpUnpause)
+	})
+	if err != nil {
 		logrus.Errorf("停止容器失败 %s %v", containerName, err)
-		return
+		return err
+	}
+	if !container.WaitForExit(containerInfo, timeout) {
+		logrus.Warnf("容器没有在 %v 内退出 发送SIGKILL %s", timeout, containerName)
+		if err := syscall.Kill(pidInt, syscall.SIGKILL); err != nil {
+			logrus.Errorf("强制停止容器失败 %s %v", containerName, err)
+			return err
+		}
+		if !container.WaitForExit(containerInfo, killTimeout) {
+			logrus.Errorf("等待容器退出超时 %s", containerName)
+			return fmt.Errorf("等待容器退出超时 %s", containerName)
+		}
+	}
+
+	// 等监控进程记录退出码 没有监控进程(如终端运行的容器)时由stop修改状态
+	// 指定了--rm的容器由监控进程删除 等到容器信息被删除为止
+	deadline := time.Now().Add(monitorRecordTimeout)
+	for {
+		if !container.ContainerExists(containerName) {
+			runPoststopHooks(containerInfo)
+			return nil
+		}
+		containerInfo, err = container.GetContainerInfoByName(containerName)
+		if err != nil {
+			return err
+		}
+		if containerInfo.Status == container.Stop && !containerInfo.AutoRemove {
+			break
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
 	}
-	containerInfo.Status = container.Stop
-	containerInfo.Pid = " "
-	newContentBytes, err := json.Marshal(containerInfo)
+	return stoppedContainer(containerName)
+}
+
+// 记录容器已经停止 然后执行poststop hook
+func stoppedContainer(containerName string) error {
+	containerInfo, err := recordContainerStop(containerName)
 	if err != nil {
-		logrus.Errorf("把停止后的容器信息序列化失败 %s %v", containerName, err)
-		return
+		return err
 	}
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirURL + container.ConfigName
-	if err := ioutil.WriteFile(configFilePath, newContentBytes, 0622); err != nil {
-		logrus.Errorf("写入文件失败 %s %v", configFilePath, err)
+	runPoststopHooks(containerInfo)
+	return nil
+}
+
+// 把容器状态改为stopped 并清理容器的网络
+func recordContainerStop(containerName string) (*container.ContainerInfo, error) {
+	containerInfo, err := container.ModifyContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status != container.Stop {
+			if err := containerInfo.Transition(container.OpStop); err != nil {
+				return err
+			}
+			containerInfo.Pid = " "
+		}
+		containerInfo.StopRequested = false
+		if containerInfo.NetworkName != "" && containerInfo.IPAddress != "" {
+			network.Init()
+			if err := network.Disconnect(containerInfo.NetworkName, containerInfo); err != nil {
+				logrus.Errorf("清理容器网络失败 %s %v", containerInfo.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("更新停止后的容器信息失败 %s %v", containerName, err)
+		return nil, err
 	}
+	return containerInfo, nil
 }
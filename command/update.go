@@ -0,0 +1,61 @@
+package command
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"yocker/cgroups"
+	"yocker/container"
+)
+
+var UpdateCommand = &cli.Command{
+	Name:  "update",
+	Usage: "修改容器的资源限制，yocker update -m 200m [container]",
+	Flags: resourceFlags,
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少容器名")
+			return errors.New("缺少容器名")
+		}
+		res := parseResourceConfig(context)
+		if res.IsEmpty() {
+			logrus.Errorf("没有指定要修改的资源限制")
+			return errors.New("没有指定要修改的资源限制")
+		}
+		for _, containerName := range context.Args().Slice() {
+			if err := updateContainer(containerName, res); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func updateContainer(containerName string, res *cgroups.ResourceConfig) error {
+	containerInfo, err := container.GetContainerInfoByName(containerName)
+	if err != nil {
+		logrus.Errorf("获取容器信息失败 %s %v", containerName, err)
+		return err
+	}
+	_, err = container.ModifyContainerInfo(containerInfo.Name, func(containerInfo *container.ContainerInfo) error {
+		if err := containerInfo.Transition(container.OpUpdate); err != nil {
+			return err
+		}
+		newRes := containerInfo.Resource.Merge(res)
+		// 创建了进程的容器直接修改cgroup created状态的容器的cgroup在create时已经创建
+		// 其他状态的容器只更新记录 重启时按记录设置
+		switch containerInfo.Status {
+		case container.Created, container.Running, container.Paused:
+			if err := cgroups.NewCgroupManager(containerInfo.Id).Set(newRes); err != nil {
+				return err
+			}
+		}
+		containerInfo.Resource = newRes
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("修改容器资源限制失败 %s %v", containerName, err)
+		return err
+	}
+	return nil
+}
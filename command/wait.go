@@ -0,0 +1,132 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sys/unix"
+	"os"
+	"time"
+	"yocker/container"
+)
+
+const (
+	// 没有监控进程时容器状态文件不会变化 定时重新读取 由Reconcile发现已经退出的容器
+	waitReconcileInterval = time.Second
+	// wait自身出错(如超时 容器不存在)时的退出码 和容器的退出码区分开
+	waitErrorExitCode = 125
+)
+
+var WaitCommand = &cli.Command{
+	Name:  "wait",
+	Usage: "等待容器退出并输出退出码，yocker wait [--timeout 60] [container...]",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "timeout",
+			Usage: "最多等待的秒数 0表示一直等待",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() < 1 {
+			logrus.Errorf("缺少容器名")
+			return errors.New("缺少容器名")
+		}
+		var deadline time.Time
+		if timeout := context.Int("timeout"); timeout > 0 {
+			deadline = time.Now().Add(time.Duration(timeout) * time.Second)
+		}
+		// 以第一个非0的退出码退出 所有容器都正常退出时返回0
+		result := 0
+		for _, containerName := range context.Args().Slice() {
+			exitCode, err := waitContainer(containerName, deadline)
+			if err != nil {
+				logrus.Errorf("等待容器退出失败 %s %v", containerName, err)
+				os.Exit(waitErrorExitCode)
+			}
+			fmt.Println(exitCode)
+			if result == 0 {
+				result = exitCode
+			}
+		}
+		os.Exit(result)
+		return nil
+	},
+}
+
+// 容器没有进程运行 也不会再被start唤醒
+func isContainerFinished(containerInfo *container.ContainerInfo) bool {
+	switch containerInfo.Status {
+	case container.Created, container.Running, container.Paused:
+		return false
+	}
+	return true
+}
+
+// 返回容器是否已经结束和退出码 --rm的容器退出后会被删除 这时从之前打开的退出码文件中读取
+func containerExitStatus(containerName string, exitFile *os.File) (bool, int, error) {
+	if container.ContainerExists(containerName) {
+		if containerInfo, err := container.GetContainerInfoByName(containerName); err == nil {
+			return isContainerFinished(containerInfo), containerInfo.ExitCode, nil
+		}
+	}
+	if exitCode, ok := container.ReadExitCode(exitFile); ok {
+		return true, exitCode, nil
+	}
+	return false, 0, fmt.Errorf("容器 %s 已经被删除", containerName)
+}
+
+// 用inotify监听容器的状态文件 容器退出后返回记录的退出码 deadline为零值时不超时
+func waitContainer(containerName string, deadline time.Time) (int, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return 0, fmt.Errorf("创建inotify失败 %v", err)
+	}
+	defer unix.Close(fd)
+	// config.json是先写临时文件再rename替换的 原来的inode不会再变化 所以监听所在的目录
+	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	mask := uint32(unix.IN_MOVED_TO | unix.IN_CLOSE_WRITE | unix.IN_DELETE_SELF)
+	if _, err := unix.InotifyAddWatch(fd, dirUrl, mask); err != nil {
+		return 0, fmt.Errorf("监听容器状态文件失败 %v", err)
+	}
+
+	exitFile, err := container.OpenExitCode(containerName)
+	if err != nil {
+		return 0, err
+	}
+	defer exitFile.Close()
+
+	buf := make([]byte, unix.SizeofInotifyEvent+unix.PathMax+1)
+	for {
+		// 先开始监听再读取状态 不会漏掉两者之间的状态变化
+		finished, exitCode, err := containerExitStatus(containerName, exitFile)
+		if err != nil {
+			return 0, err
+		}
+		if finished {
+			return exitCode, nil
+		}
+
+		interval := waitReconcileInterval
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return 0, fmt.Errorf("等待超时")
+			}
+			if remaining < interval {
+				interval = remaining
+			}
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(interval/time.Millisecond))
+		if err != nil && err != unix.EINTR {
+			return 0, fmt.Errorf("等待inotify事件失败 %v", err)
+		}
+		// 只关心有没有变化 事件内容直接丢弃 再读一次状态文件
+		if n > 0 {
+			if _, err := unix.Read(fd, buf); err != nil && err != unix.EINTR {
+				return 0, fmt.Errorf("读取inotify事件失败 %v", err)
+			}
+		}
+	}
+}
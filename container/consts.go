@@ -1,10 +1,20 @@
 package container
 
 const (
+	Created             = "created"
 	Running             = "running"
+	Paused              = "paused"
 	Stop                = "stopped"
 	Exit                = "exited"
+	Dead                = "dead"
 	DefaultInfoLocation = "/var/run/yocker/%s/"
 	ConfigName          = "config.json"
 	ContainerLogFile    = "log.log"
+	MonitorLogFile      = "monitor.log"
+	// created状态的容器的监控进程阻塞在这个fifo上 start命令写入后容器才开始运行
+	StartFifoName = "start.fifo"
+	// 最后一次退出的退出码 容器被--rm删除后等待的进程从这里读取
+	ExitCodeName = "exit_code"
+	// 修改容器信息时加锁的文件
+	lockName = "config.lock"
 )
@@ -0,0 +1,36 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func getExitCodePath(containerName string) string {
+	return fmt.Sprintf(DefaultInfoLocation, containerName) + ExitCodeName
+}
+
+// OpenExitCode 打开记录容器退出码的文件 --rm的容器退出后容器目录会被删除
+// 删除之前打开的文件仍然可以读到最后写入的退出码
+func OpenExitCode(containerName string) (*os.File, error) {
+	file, err := os.OpenFile(getExitCodePath(containerName), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开退出码文件失败 %v", err)
+	}
+	return file, nil
+}
+
+// ReadExitCode 从OpenExitCode打开的文件中读取退出码 容器还没有退出过时返回false
+func ReadExitCode(file *os.File) (int, bool) {
+	buf := make([]byte, 32)
+	n, _ := file.ReadAt(buf, 0)
+	exitCode, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	return exitCode, err == nil
+}
+
+// 原地覆盖文件的内容 不能先写临时文件再rename 否则已经打开文件的进程读不到
+func writeExitCode(containerName string, exitCode int) error {
+	return ioutil.WriteFile(getExitCodePath(containerName), []byte(strconv.Itoa(exitCode)), 0644)
+}
@@ -0,0 +1,65 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+)
+
+// 写入fifo的启动信号 没有写入就关闭表示取消启动
+const startSignal = "start"
+
+func getStartFifoPath(containerName string) string {
+	return fmt.Sprintf(DefaultInfoLocation, containerName) + StartFifoName
+}
+
+// CreateStartFifo 为created状态的容器创建start用的fifo
+func CreateStartFifo(containerName string) error {
+	fifoPath := getStartFifoPath(containerName)
+	os.Remove(fifoPath)
+	if err := syscall.Mkfifo(fifoPath, 0622); err != nil {
+		return fmt.Errorf("创建fifo失败 %s %v", fifoPath, err)
+	}
+	return nil
+}
+
+// WaitStartSignal 阻塞到有进程打开fifo写入 返回是否收到了启动信号
+func WaitStartSignal(containerName string) (bool, error) {
+	fifoPath := getStartFifoPath(containerName)
+	defer os.Remove(fifoPath)
+	fifo, err := os.OpenFile(fifoPath, os.O_RDONLY, 0)
+	if err != nil {
+		return false, fmt.Errorf("打开fifo失败 %s %v", fifoPath, err)
+	}
+	defer fifo.Close()
+	content, err := ioutil.ReadAll(fifo)
+	if err != nil {
+		return false, fmt.Errorf("读取fifo失败 %s %v", fifoPath, err)
+	}
+	return string(content) == startSignal, nil
+}
+
+// SendStartSignal 通知监控进程运行用户命令
+func SendStartSignal(containerName string) error {
+	return writeStartFifo(containerName, startSignal)
+}
+
+// CancelStart 通知监控进程不再启动容器
+func CancelStart(containerName string) error {
+	return writeStartFifo(containerName, "")
+}
+
+func writeStartFifo(containerName, content string) error {
+	fifoPath := getStartFifoPath(containerName)
+	// 非阻塞打开 没有读端(监控进程已经不在了)时会返回ENXIO 而不是一直阻塞
+	fifo, err := os.OpenFile(fifoPath, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("容器的监控进程不存在 %s %v", containerName, err)
+	}
+	defer fifo.Close()
+	if _, err := fifo.WriteString(content); err != nil {
+		return fmt.Errorf("写入fifo失败 %s %v", fifoPath, err)
+	}
+	return nil
+}
@@ -5,40 +5,137 @@ import (
 	"fmt"
 	"github.com/gofrs/uuid"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	"yocker/cgroups"
+	"yocker/fs"
+	"yocker/image"
+	"yocker/oci"
 )
 
 type ContainerInfo struct {
 	Pid         string   `json:"pid"`
 	Id          string   `json:"id"`
 	Name        string   `json:"name"`
-	Command     string   `json:"command"`
+	Command     []string `json:"command"`
 	CreateTime  string   `json:"create_time"`
 	Status      string   `json:"status"`
-	Volume      string   `json:"volume"`
+	Volumes     []string `json:"volumes"`      // -v挂载的目录 host:container
 	PortMapping []string `json:"port_mapping"` // todo 待使用
+	// 容器进程的启动时间 用于判断pid是否被复用
+	StartTime uint64 `json:"start_time"`
+	// 资源限制
+	Resource *cgroups.ResourceConfig `json:"resource"`
+	// 容器进程的退出码 被信号终止时为128+信号值
+	ExitCode int `json:"exit_code"`
+	// 终止容器进程的信号 如SIGKILL
+	ExitSignal string `json:"exit_signal,omitempty"`
+	// 容器进程退出的时间
+	FinishedAt string `json:"finished_at,omitempty"`
+	// 重启策略
+	RestartPolicy *RestartPolicy `json:"restart_policy"`
+	// 按重启策略重启的次数
+	RestartCount int `json:"restart_count"`
+	// 以下是restart重新创建容器进程时需要的参数
+	ImageName   string   `json:"image_name"`
+	ImageID     string   `json:"image_id,omitempty"` // 镜像名指向其他镜像后容器仍然使用创建时的镜像
+	Env         []string `json:"env"`
+	NetworkName string   `json:"network_name"`
+	// 容器在网络中的ip 重启后继续使用
+	IPAddress string `json:"ip_address"`
+	// stop时发送给容器进程的信号
+	StopSignal string `json:"stop_signal,omitempty"`
+	// 容器正在被stop 进程退出后状态为stopped 不按重启策略重启
+	StopRequested bool `json:"stop_requested,omitempty"`
+	// 容器退出后自动删除
+	AutoRemove bool `json:"auto_remove,omitempty"`
+	// 由yocker init作为容器的1号进程运行用户命令
+	Init bool `json:"init,omitempty"`
+	// 运行用户命令的用户 工作目录和容器的主机名
+	User       string `json:"user,omitempty"`
+	WorkingDir string `json:"working_dir,omitempty"`
+	Hostname   string `json:"hostname,omitempty"`
+	// 按OCI bundle创建的容器的bundle目录和rootfs 这类容器没有overlay读写层
+	Bundle string `json:"bundle,omitempty"`
+	Rootfs string `json:"rootfs,omitempty"`
+	// 镜像运行的容器overlay的各层目录
+	LowerDir  string `json:"lower_dir,omitempty"`
+	UpperDir  string `json:"upper_dir,omitempty"`
+	WorkDir   string `json:"work_dir,omitempty"`
+	MergedDir string `json:"merged_dir,omitempty"`
+	// 容器生命周期中执行的hook
+	Hooks *oci.Hooks `json:"hooks,omitempty"`
 }
 
-func RecordContainerInfo(containerPid int, cmdArr []string, containerName, volume string) (*ContainerInfo, error) {
+// UnmarshalJSON 旧版本的容器信息中command是用空格拼接的字符串 读取时转换为[]string
+// volume是单个字符串 读取时转换为volumes
+func (c *ContainerInfo) UnmarshalJSON(data []byte) error {
+	type plainInfo ContainerInfo
+	aux := struct {
+		*plainInfo
+		Command json.RawMessage `json:"command"`
+		Volume  string          `json:"volume"`
+	}{plainInfo: (*plainInfo)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(c.Volumes) == 0 && aux.Volume != "" {
+		c.Volumes = []string{aux.Volume}
+	}
+	c.Command = nil
+	if len(aux.Command) == 0 || string(aux.Command) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(aux.Command, &c.Command); err == nil {
+		return nil
+	}
+	var command string
+	if err := json.Unmarshal(aux.Command, &command); err != nil {
+		return err
+	}
+	c.Command = strings.Fields(command)
+	return nil
+}
+
+// NewContainerId 生成容器id 容器的cgroup等资源都以id命名
+func NewContainerId() string {
 	uid, _ := uuid.NewV4()
-	id := uid.String()
+	return uid.String()
+}
+
+// RecordContainerInfo 记录新创建的容器 lowerDir为空时容器没有overlay读写层 如bundle运行的容器
+func RecordContainerInfo(containerPid int, cmdArr []string, id, containerName, lowerDir string, volumes []string, res *cgroups.ResourceConfig) (*ContainerInfo, error) {
 	createTime := time.Now().Format("2006-01-02 15:04:05")
-	cmd := strings.Join(cmdArr, "")
 	if containerName == "" {
 		containerName = id
 	}
+	startTime, err := GetProcessStartTime(containerPid)
+	if err != nil {
+		logrus.Errorf("获取容器进程启动时间失败 %v", err)
+		return nil, err
+	}
 	cInfo := &ContainerInfo{
 		Id:         id,
 		Pid:        strconv.Itoa(containerPid),
+		StartTime:  startTime,
 		Name:       containerName,
-		Command:    cmd,
+		Command:    cmdArr,
 		CreateTime: createTime,
-		Status:     Running,
-		Volume:     volume,
+		Status:     Created,
+		Volumes:    volumes,
+		Resource:   res,
+	}
+	if lowerDir != "" {
+		cInfo.LowerDir = lowerDir
+		cInfo.UpperDir = fs.GetUpper(containerName)
+		cInfo.WorkDir = fs.GetWorker(containerName)
+		cInfo.MergedDir = fs.GetMerged(containerName)
 	}
 
 	jsonBytes, err := json.Marshal(cInfo)
@@ -66,7 +163,7 @@ func RecordContainerInfo(containerPid int, cmdArr []string, containerName, volum
 	return cInfo, nil
 }
 
-func DeleteContainerInfo(containerName string, volume string) error {
+func DeleteContainerInfo(containerName string) error {
 	dirUrl := fmt.Sprintf(DefaultInfoLocation, containerName)
 	if err := os.RemoveAll(dirUrl); err != nil {
 		logrus.Errorf("删除容器信息文件失败 %v", err)
@@ -75,7 +172,41 @@ func DeleteContainerInfo(containerName string, volume string) error {
 	return nil
 }
 
+// ContainerExists 判断容器信息是否存在
+func ContainerExists(containerName string) bool {
+	_, err := os.Stat(fmt.Sprintf(DefaultInfoLocation, containerName) + ConfigName)
+	return err == nil
+}
+
+// GetContainerInfoByName 读取容器信息 读取时会先检查容器进程是否还存在
 func GetContainerInfoByName(containerName string) (*ContainerInfo, error) {
+	containerInfo, err := readContainerInfo(containerName)
+	if err != nil {
+		return nil, err
+	}
+	if err := Reconcile(containerInfo); err != nil {
+		logrus.Errorf("同步容器状态失败 %s %v", containerName, err)
+	}
+	return containerInfo, nil
+}
+
+// RecordContainerRestart 重新启动已经存在的容器时 只更新容器进程的pid和启动时间
+func RecordContainerRestart(containerPid int, containerName string) (*ContainerInfo, error) {
+	startTime, err := GetProcessStartTime(containerPid)
+	if err != nil {
+		logrus.Errorf("获取容器进程启动时间失败 %v", err)
+		return nil, err
+	}
+	return ModifyContainerInfo(containerName, func(containerInfo *ContainerInfo) error {
+		containerInfo.Pid = strconv.Itoa(containerPid)
+		containerInfo.StartTime = startTime
+		containerInfo.ExitSignal = ""
+		containerInfo.StopRequested = false
+		return nil
+	})
+}
+
+func readContainerInfo(containerName string) (*ContainerInfo, error) {
 	dirURL := fmt.Sprintf(DefaultInfoLocation, containerName)
 	configFilePath := dirURL + ConfigName
 	contentBytes, err := ioutil.ReadFile(configFilePath)
@@ -89,5 +220,149 @@ func GetContainerInfoByName(containerName string) (*ContainerInfo, error) {
 		logrus.Errorf("序列化容器信息失败 %v", err)
 		return nil, err
 	}
+	migrateContainerInfo(&containerInfo)
 	return &containerInfo, nil
 }
+
+// 旧版本的容器信息中没有镜像和rootfs的目录 读取时在内存中补全
+// 不单独写回 之后通过ModifyContainerInfo修改容器信息时一起写入
+func migrateContainerInfo(containerInfo *ContainerInfo) {
+	if containerInfo.Bundle != "" || containerInfo.MergedDir != "" {
+		return
+	}
+	containerInfo.UpperDir = fs.GetUpper(containerInfo.Name)
+	containerInfo.WorkDir = fs.GetWorker(containerInfo.Name)
+	containerInfo.MergedDir = fs.GetMerged(containerInfo.Name)
+	// 旧版本把镜像解压在/opt/yocker/<镜像名>下直接作为lowerdir 从还挂载着的overlay中找到镜像名
+	if containerInfo.ImageName == "" {
+		if lowerDir := fs.MountedLowerDir(containerInfo.Name); lowerDir != "" {
+			containerInfo.LowerDir = lowerDir
+			if filepath.Dir(filepath.Clean(lowerDir)) == filepath.Clean(fs.RootUrl) {
+				containerInfo.ImageName = filepath.Base(lowerDir)
+			}
+		}
+	}
+	// 找不到镜像时重启仍然按镜像名查找
+	if containerInfo.ImageID == "" && containerInfo.ImageName != "" {
+		if idx, err := image.Load(); err == nil {
+			if img, err := idx.Lookup(containerInfo.ImageName); err == nil {
+				containerInfo.ImageID = img.ID
+				containerInfo.LowerDir = img.LowerDir()
+			}
+		}
+	}
+}
+
+// ModifyContainerInfo 加锁后读取容器信息 fn修改后写回 返回修改后的容器信息
+// 监控进程记录退出状态和其他命令同步容器状态同时发生时 后写入的不会覆盖先写入的修改
+func ModifyContainerInfo(containerName string, fn func(containerInfo *ContainerInfo) error) (*ContainerInfo, error) {
+	lock, err := os.OpenFile(fmt.Sprintf(DefaultInfoLocation, containerName)+lockName, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		logrus.Errorf("打开容器锁失败 %s %v", containerName, err)
+		return nil, err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		logrus.Errorf("获取容器锁失败 %s %v", containerName, err)
+		return nil, err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	containerInfo, err := readContainerInfo(containerName)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(containerInfo); err != nil {
+		return nil, err
+	}
+	if err := writeContainerInfo(containerInfo); err != nil {
+		return nil, err
+	}
+	return containerInfo, nil
+}
+
+// 把修改后的容器信息写回config.json 只能在持有容器锁时调用 修改容器信息使用ModifyContainerInfo
+// 先写临时文件再rename 其他进程不会读到写了一半的文件
+func writeContainerInfo(containerInfo *ContainerInfo) error {
+	contentBytes, err := json.Marshal(containerInfo)
+	if err != nil {
+		logrus.Errorf("序列化容器信息失败 %s %v", containerInfo.Name, err)
+		return err
+	}
+	configFilePath := fmt.Sprintf(DefaultInfoLocation, containerInfo.Name) + ConfigName
+	tmpFilePath := configFilePath + ".tmp"
+	if err := ioutil.WriteFile(tmpFilePath, contentBytes, 0622); err != nil {
+		logrus.Errorf("写入容器信息失败 %s %v", tmpFilePath, err)
+		return err
+	}
+	if err := os.Rename(tmpFilePath, configFilePath); err != nil {
+		logrus.Errorf("写入容器信息失败 %s %v", configFilePath, err)
+		return err
+	}
+	return nil
+}
+
+// RecordContainerExit 容器进程退出后记录退出码 信号和退出时间
+// 其他命令可能已经发现进程不存在并记录了未知的退出码 这里用真实的退出码覆盖
+func RecordContainerExit(containerName string, pid int, status syscall.WaitStatus) error {
+	_, err := ModifyContainerInfo(containerName, func(containerInfo *ContainerInfo) error {
+		// 容器已经被restart重新创建了进程 这次退出的是旧进程 不再记录
+		recordedPid := strings.TrimSpace(containerInfo.Pid)
+		if recordedPid != "" && recordedPid != strconv.Itoa(pid) {
+			return nil
+		}
+		containerInfo.ExitCode = ExitCode(status)
+		if status.Signaled() {
+			containerInfo.ExitSignal = unix.SignalName(status.Signal())
+		}
+		// 被stop停止的容器状态为stopped 其他情况为exited
+		op := OpExit
+		if containerInfo.StopRequested && containerInfo.Status != Stop {
+			op = OpStop
+		}
+		if err := containerInfo.Transition(op); err != nil {
+			logrus.Errorf("记录容器退出状态失败 %v", err)
+			return err
+		}
+		containerInfo.Pid = " "
+		containerInfo.FinishedAt = time.Now().Format("2006-01-02 15:04:05")
+		if err := writeExitCode(containerName, containerInfo.ExitCode); err != nil {
+			logrus.Warnf("写入退出码失败 %s %v", containerName, err)
+		}
+		return nil
+	})
+	return err
+}
+
+// ExitCode 按shell的约定把进程的退出状态转换为退出码 被信号杀死时为128+信号值
+func ExitCode(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
+// ListContainers 读取/var/run/yocker下所有容器的信息 没有config.json的目录(如network)会被跳过
+func ListContainers() ([]*ContainerInfo, error) {
+	dirURL := fmt.Sprintf(DefaultInfoLocation, "")
+	files, err := ioutil.ReadDir(dirURL)
+	if err != nil {
+		logrus.Errorf("读取容器文件目录失败 %v", err)
+		return nil, err
+	}
+	var containers []*ContainerInfo
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(fmt.Sprintf(DefaultInfoLocation, file.Name()) + ConfigName); err != nil {
+			continue
+		}
+		containerInfo, err := GetContainerInfoByName(file.Name())
+		if err != nil {
+			continue
+		}
+		containers = append(containers, containerInfo)
+	}
+	return containers, nil
+}
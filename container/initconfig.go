@@ -0,0 +1,72 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// InitConfig run通过管道发送给容器init进程的启动信息 init进程按照它准备好环境后运行用户命令
+type InitConfig struct {
+	// 用户命令 Args[0]为要运行的程序
+	Args []string `json:"args"`
+	// 用户命令的环境变量 KEY=VALUE
+	Env []string `json:"env"`
+	// 用户命令的工作目录 为空时为/
+	Cwd string `json:"cwd"`
+	// 运行用户命令的用户 uid[:gid] 为空时为root
+	User string `json:"user,omitempty"`
+	// 容器的主机名
+	Hostname string `json:"hostname,omitempty"`
+	// 在rootfs中依次挂载 Destination是容器内的路径
+	Mounts []Mount `json:"mounts"`
+	// 为true时把rootfs重新挂载为只读
+	ReadonlyRootfs bool `json:"readonly_rootfs,omitempty"`
+	// 为true时init作为1号进程留在容器中 转发信号并回收僵尸进程
+	Init bool `json:"init,omitempty"`
+}
+
+// Mount 对应一次mount系统调用
+type Mount struct {
+	Source      string  `json:"source"`
+	Destination string  `json:"destination"`
+	Type        string  `json:"type"`
+	Flags       uintptr `json:"flags"`
+	Data        string  `json:"data,omitempty"`
+}
+
+// DefaultMounts 每个容器都需要的proc和/dev
+func DefaultMounts() []Mount {
+	return []Mount{
+		{
+			Source:      "proc",
+			Destination: "/proc",
+			Type:        "proc",
+			Flags:       syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV,
+		},
+		{
+			Source:      "tmpfs",
+			Destination: "/dev",
+			Type:        "tmpfs",
+			Flags:       syscall.MS_NOSUID | syscall.MS_STRICTATIME,
+			Data:        "mode=755",
+		},
+	}
+}
+
+// ParseUser 解析uid[:gid] 只支持数字 不指定gid时和uid相同
+func ParseUser(user string) (int, int, error) {
+	parts := strings.SplitN(user, ":", 2)
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil || uid < 0 {
+		return 0, 0, fmt.Errorf("无效的用户 %s", user)
+	}
+	gid := uid
+	if len(parts) == 2 {
+		if gid, err = strconv.Atoi(parts[1]); err != nil || gid < 0 {
+			return 0, 0, fmt.Errorf("无效的用户组 %s", user)
+		}
+	}
+	return uid, gid, nil
+}
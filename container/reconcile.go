@@ -0,0 +1,102 @@
+package container
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 容器进程丢失时无法得知退出码
+const unknownExitCode = -1
+
+// 读取/proc/<pid>/stat中的第3列进程状态和第22列starttime
+// starttime是进程启动时距系统启动的时钟周期数 pid被复用后新进程的starttime不同
+func readProcessStat(pid int) (state string, startTime uint64, err error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", 0, err
+	}
+	// 第2列是括号包起来的进程名 可能包含空格 从最后一个右括号之后开始解析
+	stat := string(content)
+	index := strings.LastIndex(stat, ")")
+	if index < 0 {
+		return "", 0, fmt.Errorf("解析进程状态失败 %d", pid)
+	}
+	// 右括号之后从第3列开始
+	fields := strings.Fields(stat[index+1:])
+	if len(fields) < 20 {
+		return "", 0, fmt.Errorf("解析进程状态失败 %d", pid)
+	}
+	startTime, err = strconv.ParseUint(fields[19], 10, 64)
+	return fields[0], startTime, err
+}
+
+// GetProcessStartTime 获取进程的启动时间 用来判断记录的pid是否还是原来的容器进程
+func GetProcessStartTime(pid int) (uint64, error) {
+	_, startTime, err := readProcessStat(pid)
+	return startTime, err
+}
+
+// IsProcessAlive 判断记录的容器进程是否还活着
+func IsProcessAlive(containerInfo *ContainerInfo) bool {
+	pid, err := strconv.Atoi(strings.TrimSpace(containerInfo.Pid))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	state, startTime, err := readProcessStat(pid)
+	// 僵尸进程已经退出 只是还没有被回收
+	if err != nil || state == "Z" {
+		return false
+	}
+	// 旧版本没有记录启动时间 只能判断进程是否存在
+	return containerInfo.StartTime == 0 || containerInfo.StartTime == startTime
+}
+
+// WaitForExit 等待容器进程退出 超时返回false
+func WaitForExit(containerInfo *ContainerInfo, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for IsProcessAlive(containerInfo) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+// Reconcile 检查记录为有进程的容器 进程已经不存在或pid被复用时把容器标记为exited
+func Reconcile(containerInfo *ContainerInfo) error {
+	if !needsReconcile(containerInfo) {
+		return nil
+	}
+	// 加锁后重新读取 监控进程可能已经记录了真实的退出码
+	updated, err := ModifyContainerInfo(containerInfo.Name, func(current *ContainerInfo) error {
+		if !needsReconcile(current) {
+			return nil
+		}
+		logrus.Warnf("容器进程已经不存在 %s %s", current.Name, current.Pid)
+		if err := current.Transition(OpExit); err != nil {
+			return err
+		}
+		current.Pid = " "
+		current.ExitCode = unknownExitCode
+		current.FinishedAt = time.Now().Format("2006-01-02 15:04:05")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*containerInfo = *updated
+	return nil
+}
+
+func needsReconcile(containerInfo *ContainerInfo) bool {
+	switch containerInfo.Status {
+	case Created, Running, Paused:
+		return !IsProcessAlive(containerInfo)
+	}
+	return false
+}
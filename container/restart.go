@@ -0,0 +1,95 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RestartNo            = "no"
+	RestartOnFailure     = "on-failure"
+	RestartAlways        = "always"
+	RestartUnlessStopped = "unless-stopped"
+
+	// 重启的退避时间从100ms开始翻倍 最长1分钟
+	restartBackoffMin = 100 * time.Millisecond
+	restartBackoffMax = time.Minute
+	// 容器运行超过这个时间后再退出 退避时间重新开始计算
+	restartBackoffReset = 10 * time.Second
+)
+
+// RestartPolicy 容器退出后监控进程是否重新启动容器
+type RestartPolicy struct {
+	Name string `json:"name"`
+	// on-failure的最大重启次数 0表示不限制
+	MaximumRetryCount int `json:"maximum_retry_count,omitempty"`
+}
+
+// ParseRestartPolicy 解析 no on-failure[:N] always unless-stopped
+func ParseRestartPolicy(policy string) (*RestartPolicy, error) {
+	if policy == "" {
+		return &RestartPolicy{Name: RestartNo}, nil
+	}
+	parts := strings.SplitN(policy, ":", 2)
+	restartPolicy := &RestartPolicy{Name: parts[0]}
+	switch parts[0] {
+	case RestartNo, RestartAlways, RestartUnlessStopped:
+		if len(parts) == 2 {
+			return nil, fmt.Errorf("重启策略 %s 不能指定重启次数", parts[0])
+		}
+	case RestartOnFailure:
+		if len(parts) == 2 {
+			count, err := strconv.Atoi(parts[1])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("重启次数格式错误 %s", policy)
+			}
+			restartPolicy.MaximumRetryCount = count
+		}
+	default:
+		return nil, fmt.Errorf("不支持的重启策略 %s", policy)
+	}
+	return restartPolicy, nil
+}
+
+func (p *RestartPolicy) String() string {
+	if p == nil {
+		return RestartNo
+	}
+	if p.Name == RestartOnFailure && p.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaximumRetryCount)
+	}
+	return p.Name
+}
+
+// ShouldRestart 容器进程退出后是否需要重启 被stop停止的容器都不重启
+// 没有常驻的守护进程 always和unless-stopped只在监控进程存活期间生效 两者行为一致
+func ShouldRestart(containerInfo *ContainerInfo) bool {
+	policy := containerInfo.RestartPolicy
+	if policy == nil || containerInfo.Status != Exit || containerInfo.StopRequested {
+		return false
+	}
+	switch policy.Name {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		if containerInfo.ExitCode == 0 {
+			return false
+		}
+		return policy.MaximumRetryCount == 0 || containerInfo.RestartCount < policy.MaximumRetryCount
+	}
+	return false
+}
+
+// RestartBackoff 计算下次重启前的等待时间 running是容器这次运行的时长
+func RestartBackoff(previous, running time.Duration) time.Duration {
+	if previous == 0 || running >= restartBackoffReset {
+		return restartBackoffMin
+	}
+	next := previous * 2
+	if next > restartBackoffMax {
+		next = restartBackoffMax
+	}
+	return next
+}
@@ -0,0 +1,41 @@
+package container
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// 没有指定stop-signal时stop发送的信号
+const DefaultStopSignal = "SIGTERM"
+
+// ParseSignal 解析信号 支持 SIGTERM TERM 15 三种写法
+func ParseSignal(signal string) (syscall.Signal, error) {
+	if num, err := strconv.Atoi(signal); err == nil {
+		if num <= 0 || unix.SignalName(syscall.Signal(num)) == "" {
+			return 0, fmt.Errorf("无效的信号 %s", signal)
+		}
+		return syscall.Signal(num), nil
+	}
+	name := strings.ToUpper(signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, fmt.Errorf("无效的信号 %s", signal)
+	}
+	return sig, nil
+}
+
+// GetStopSignal 获取容器stop时要发送的信号
+func (c *ContainerInfo) GetStopSignal() syscall.Signal {
+	if c.StopSignal != "" {
+		if sig, err := ParseSignal(c.StopSignal); err == nil {
+			return sig
+		}
+	}
+	return syscall.SIGTERM
+}
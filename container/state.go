@@ -0,0 +1,121 @@
+package container
+
+import (
+	"fmt"
+)
+
+// Operation 会改变或依赖容器状态的操作
+type Operation string
+
+const (
+	OpStart   Operation = "start"
+	OpStop    Operation = "stop"
+	OpKill    Operation = "kill"
+	OpPause   Operation = "pause"
+	OpUnpause Operation = "unpause"
+	OpRestart Operation = "restart"
+	OpRemove  Operation = "rm"
+	OpExec    Operation = "exec"
+	OpUpdate  Operation = "update"
+	OpCommit  Operation = "commit"
+	// OpExit 容器进程退出 由监控进程触发
+	OpExit Operation = "exit"
+	// OpDie 删除容器时清理到一半失败 容器的资源只清理了一部分
+	OpDie Operation = "die"
+)
+
+// 状态转移表 当前状态 -> 操作 -> 操作后的状态
+// 不在表中的操作在该状态下都是不允许的 rm后容器信息被删除 所以目标状态为空
+var transitions = map[string]map[Operation]string{
+	Created: {
+		OpStart:  Running,
+		OpRemove: "",
+		OpUpdate: Created,
+		OpExit:   Exit,
+		OpDie:    Dead,
+	},
+	Running: {
+		OpStop:    Stop,
+		OpKill:    Running,
+		OpPause:   Paused,
+		OpRestart: Running,
+		OpExec:    Running,
+		OpUpdate:  Running,
+		OpCommit:  Running,
+		OpExit:    Exit,
+	},
+	Paused: {
+		OpStop:    Stop,
+		OpUnpause: Running,
+		OpRestart: Running,
+		OpUpdate:  Paused,
+		OpCommit:  Paused,
+		OpExit:    Exit,
+		// 被冻结的进程解冻后才会处理信号 kill发送信号后解冻容器
+		OpKill: Running,
+	},
+	Stop: {
+		OpStart:   Running,
+		OpRestart: Running,
+		OpRemove:  "",
+		OpUpdate:  Stop,
+		OpCommit:  Stop,
+		// 被stop停止的容器退出后保持stopped状态
+		OpExit: Stop,
+		OpDie:  Dead,
+	},
+	Exit: {
+		// 已经退出的容器stop后变为stopped 不会再按重启策略重启
+		OpStop:    Stop,
+		OpStart:   Running,
+		OpRestart: Running,
+		OpRemove:  "",
+		OpUpdate:  Exit,
+		OpCommit:  Exit,
+		OpExit:    Exit,
+		OpDie:     Dead,
+	},
+	// 只能再次rm 重新清理剩下的资源
+	Dead: {
+		OpRemove: "",
+		OpDie:    Dead,
+	},
+}
+
+// StateError 容器当前状态不允许执行该操作
+type StateError struct {
+	Name   string
+	Status string
+	Op     Operation
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("容器 %s 处于 %s 状态 不能执行 %s", e.Name, e.Status, e.Op)
+}
+
+// NextStatus 返回容器在status状态下执行op后的状态 不允许时返回StateError
+func NextStatus(name, status string, op Operation) (string, error) {
+	next, ok := transitions[status][op]
+	if !ok {
+		return "", &StateError{Name: name, Status: status, Op: op}
+	}
+	return next, nil
+}
+
+// Transition 检查操作是否允许 并把容器状态修改为操作后的状态 调用方负责持久化
+func (c *ContainerInfo) Transition(op Operation) error {
+	next, err := NextStatus(c.Name, c.Status, op)
+	if err != nil {
+		return err
+	}
+	if next != "" {
+		c.Status = next
+	}
+	return nil
+}
+
+// CanTransition 只检查操作是否允许 不修改状态
+func (c *ContainerInfo) CanTransition(op Operation) error {
+	_, err := NextStatus(c.Name, c.Status, op)
+	return err
+}
@@ -0,0 +1,61 @@
+package container
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDieFromStoppedStates(t *testing.T) {
+	for _, status := range []string{Created, Stop, Exit, Dead} {
+		next, err := NextStatus("c1", status, OpDie)
+		if err != nil {
+			t.Errorf("%s 状态下清理失败应该变为dead %v", status, err)
+			continue
+		}
+		if next != Dead {
+			t.Errorf("%s 状态下清理失败后为 %s 期望 %s", status, next, Dead)
+		}
+	}
+	// 有进程的容器不会被清理
+	for _, status := range []string{Running, Paused} {
+		if _, err := NextStatus("c1", status, OpDie); err == nil {
+			t.Errorf("%s 状态下不应该变为dead", status)
+		}
+	}
+}
+
+func TestDeadOnlyAllowsRemove(t *testing.T) {
+	info := &ContainerInfo{Name: "c1", Status: Dead}
+	for _, op := range []Operation{OpStart, OpStop, OpKill, OpPause, OpUnpause, OpRestart, OpExec, OpUpdate, OpCommit, OpExit} {
+		err := info.CanTransition(op)
+		var stateErr *StateError
+		if !errors.As(err, &stateErr) {
+			t.Errorf("dead状态下 %s 应该返回StateError 实际为 %v", op, err)
+		}
+	}
+	if err := info.Transition(OpRemove); err != nil {
+		t.Fatalf("dead状态下应该可以删除 %v", err)
+	}
+	// rm之后容器信息被删除 状态不变
+	if info.Status != Dead {
+		t.Errorf("删除后状态为 %s", info.Status)
+	}
+}
+
+func TestKillThawsPausedContainer(t *testing.T) {
+	info := &ContainerInfo{Name: "c1", Status: Paused}
+	if err := info.Transition(OpKill); err != nil {
+		t.Fatalf("paused状态下应该可以kill %v", err)
+	}
+	if info.Status != Running {
+		t.Errorf("kill之后状态为 %s 期望 %s", info.Status, Running)
+	}
+	// stop发送信号解冻后先恢复为running 退出后再记录为stopped
+	info.Status = Paused
+	if err := info.Transition(OpUnpause); err != nil {
+		t.Fatal(err)
+	}
+	if err := info.Transition(OpStop); err != nil || info.Status != Stop {
+		t.Errorf("解冻后stop失败 状态为 %s %v", info.Status, err)
+	}
+}
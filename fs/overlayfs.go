@@ -3,31 +3,20 @@ package fs
 import (
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
 const (
 	RootUrl         = "/opt/yocker/"
-	lowerDirFormat  = "/opt/yocker/%s"
 	upperDirFormat  = "/opt/yocker/%s/upper/"
 	workDirFormat   = "/opt/yocker/%s/work/"
 	mergedDirFormat = "/opt/yocker/%s/merged/"
 )
 
-func getImage(imageName string) string {
-	return RootUrl + imageName + ".tar"
-}
-
-func getUnTar(imageName string) string {
-	return RootUrl + imageName + "/"
-}
-
-func getLower(imageName string) string {
-	return fmt.Sprintf(lowerDirFormat, imageName)
-}
-
 func getUpper(containerName string) string {
 	return fmt.Sprintf(upperDirFormat, containerName)
 }
@@ -40,32 +29,35 @@ func getMerged(containerName string) string {
 	return fmt.Sprintf(mergedDirFormat, containerName)
 }
 
-func GetUnTar(imageName string) string {
-	return RootUrl + imageName + "/"
+func GetUpper(containerName string) string {
+	return fmt.Sprintf(upperDirFormat, containerName)
 }
 
-func GetImage(imageName string) string {
-	return RootUrl + imageName + ".tar"
+func GetWorker(containerName string) string {
+	return fmt.Sprintf(workDirFormat, containerName)
 }
 
 func GetMerged(containerName string) string {
 	return fmt.Sprintf(mergedDirFormat, containerName)
 }
 
-func NewWorkSpace(imageName, containerName, volume string) {
-	CreateReadOnlyLayer(imageName)
+// NewWorkSpace lowerDir是镜像所有layer的目录 上层在前用:分隔 restart为true时复用已经挂载好的overlay
+func NewWorkSpace(lowerDir, containerName string, volumes []string, restart bool) error {
 	CreateWriteLayer(containerName)
-	CreateMountPoint(containerName, imageName)
+	if err := CreateMountPoint(containerName, lowerDir, restart); err != nil {
+		return err
+	}
 	// 判断用户是否执行挂载操作
-	if volume != "" {
+	for _, volume := range volumes {
 		volumeURLs := strings.Split(volume, ":")
 		length := len(volumeURLs)
 		if length == 2 && volumeURLs[0] != "" && volumeURLs[1] != "" {
 			MountVolume(containerName, volumeURLs)
 		} else {
-			logrus.Errorf("挂载格式不正确")
+			logrus.Errorf("挂载格式不正确 %s", volume)
 		}
 	}
+	return nil
 }
 
 func MountVolume(containerName string, volumeURLs []string) {
@@ -78,6 +70,10 @@ func MountVolume(containerName string, volumeURLs []string) {
 	// 在容器中创建挂载点
 	mntURL := getMerged(containerName)
 	containerVolumeURL := mntURL + containerUrl
+	// 容器重启时volume可能已经挂载了
+	if IsMountPoint(containerVolumeURL) {
+		return
+	}
 	if err := os.MkdirAll(containerVolumeURL, 0777); err != nil {
 		logrus.Errorf("创建容器文件目录%s失败: %v", containerVolumeURL, err)
 		return
@@ -92,21 +88,31 @@ func MountVolume(containerName string, volumeURLs []string) {
 	}
 }
 
-func CreateMountPoint(containerName, imageName string) {
+func CreateMountPoint(containerName, lowerDir string, reuse bool) error {
 	mntURL := getMerged(containerName)
 	// // mount -t overlay overlay -o lowerdir=lower1:lower2:lower3,upperdir=upper,workdir=work merged
 	if err := os.MkdirAll(mntURL, 0777); err != nil {
 		logrus.Errorf("创建 %s 失败 %v", mntURL, err)
-		return
+		return err
+	}
+	// 容器重启时复用已经挂载好的overlay 新建的容器不能使用其他容器留下的挂载
+	if IsMountPoint(mntURL) {
+		if reuse {
+			return nil
+		}
+		logrus.Errorf("%s 已经被挂载", mntURL)
+		return fmt.Errorf("%s 已经被挂载", mntURL)
 	}
 
-	dirs := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", getLower(imageName), getUpper(containerName), getWorker(containerName))
+	dirs := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lowerDir, getUpper(containerName), getWorker(containerName))
 	cmd := exec.Command("mount", "-t", "overlay", "overlay", "-o", dirs, mntURL)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		logrus.Errorf("%v", err)
+		logrus.Errorf("挂载overlay失败 %v", err)
+		return err
 	}
+	return nil
 }
 
 // upper层 work层
@@ -123,25 +129,53 @@ func CreateWriteLayer(containerName string) {
 	}
 }
 
-// 只读层 lower层
-func CreateReadOnlyLayer(imageName string) {
-	imageURL := getUnTar(imageName)
-	imageTarURL := getImage(imageName)
-	exist, err := PathExists(imageURL)
+// IsMountPoint 判断目录是否是挂载点 /proc/self/mountinfo的第5列是挂载点
+func IsMountPoint(url string) bool {
+	content, err := ioutil.ReadFile("/proc/self/mountinfo")
 	if err != nil {
-		logrus.Errorf("无法判断%s是否存在，%v", imageURL, err)
-		return
+		logrus.Errorf("读取挂载信息失败 %v", err)
+		return false
+	}
+	target := filepath.Clean(url)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 5 && fields[4] == target {
+			return true
+		}
 	}
-	if !exist {
-		if err := os.MkdirAll(imageURL, 0777); err != nil {
-			logrus.Errorf("创建%s 失败 %v", imageURL, err)
-			return
+	return false
+}
+
+// MountedLowerDir 容器的overlay还挂载着时返回挂载时使用的lowerdir
+func MountedLowerDir(containerName string) string {
+	return findOverlayLowerDir("/proc/self/mountinfo", getMerged(containerName))
+}
+
+// 从mountinfo中找到挂载在mntURL上的overlay 超级块选项中有lowerdir
+// 40 25 0:35 / /opt/yocker/c1/merged rw,relatime - overlay overlay rw,lowerdir=/opt/yocker/busybox,upperdir=...
+func findOverlayLowerDir(mountInfo, mntURL string) string {
+	content, err := ioutil.ReadFile(mountInfo)
+	if err != nil {
+		return ""
+	}
+	target := filepath.Clean(mntURL)
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(line, " - ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[0])
+		postFields := strings.Fields(parts[1])
+		if len(fields) < 5 || fields[4] != target || len(postFields) < 3 || postFields[0] != "overlay" {
+			continue
 		}
-		if _, err := exec.Command("tar", "-xvf", imageTarURL, "-C", imageURL).CombinedOutput(); err != nil {
-			logrus.Errorf("解压 %s 失败 %v", imageTarURL, err)
-			return
+		for _, opt := range strings.Split(postFields[2], ",") {
+			if strings.HasPrefix(opt, "lowerdir=") {
+				return strings.TrimPrefix(opt, "lowerdir=")
+			}
 		}
 	}
+	return ""
 }
 
 func PathExists(url string) (bool, error) {
@@ -155,49 +189,72 @@ func PathExists(url string) (bool, error) {
 	return false, err
 }
 
-func DeleteWorkSpace(containerName, volume string) {
+// DeleteWorkSpace 卸载volume和overlay后删除容器的读写层
+// 卸载失败时不能删除目录 否则会通过挂载点删除宿主机或者镜像中的文件
+func DeleteWorkSpace(containerName string, volumes []string) error {
 	mntURL := getMerged(containerName)
 	rootURL := RootUrl
-	if volume != "" {
+	for _, volume := range volumes {
 		volumeURLs := strings.Split(volume, ":")
 		length := len(volumeURLs)
 		if length == 2 && volumeURLs[0] != "" && volumeURLs[1] != "" {
-			umountVolume(mntURL, volumeURLs)
+			if err := umountVolume(mntURL, volumeURLs); err != nil {
+				return err
+			}
 		}
 	}
-	DeleteMountPoint(rootURL, mntURL)
-	DeleteWriteLayer(rootURL)
-
+	if err := DeleteMountPoint(rootURL, mntURL); err != nil {
+		return err
+	}
+	return DeleteWriteLayer(containerName)
 }
 
-func umountVolume(mntURL string, volumeURLs []string) {
-	// 卸载容器里volume挂载点
+func umountVolume(mntURL string, volumeURLs []string) error {
+	// 卸载容器里volume挂载点 已经卸载过时跳过 删除失败的容器再次删除时不会出错
 	containerUrl := mntURL + volumeURLs[1]
-	if _, err := exec.Command("umount", containerUrl).CombinedOutput(); err != nil {
-		logrus.Errorf("卸载volume挂载失败")
-		return
+	if !IsMountPoint(containerUrl) {
+		return nil
+	}
+	if output, err := exec.Command("umount", containerUrl).CombinedOutput(); err != nil {
+		logrus.Errorf("卸载volume挂载失败 %s %v %s", containerUrl, err, strings.TrimSpace(string(output)))
+		return fmt.Errorf("卸载volume %s 失败 %v", containerUrl, err)
 	}
+	return nil
 }
 
-func DeleteWriteLayer(rootURL string) {
-	writeURL := rootURL + "upper/"
+// 删除容器的upper层和work层
+func DeleteWriteLayer(containerName string) error {
+	writeURL := getUpper(containerName)
 	if err := os.RemoveAll(writeURL); err != nil {
 		logrus.Errorf("删除目录失败 %s error %v", writeURL, err)
+		return err
 	}
-	workURL := rootURL + "work"
+	workURL := getWorker(containerName)
 	if err := os.RemoveAll(workURL); err != nil {
 		logrus.Errorf("删除目录失败 %s error %v", workURL, err)
+		return err
+	}
+	// 之前版本的镜像也解压在/opt/yocker下 容器名和镜像名相同时这个目录不为空 只删除空目录
+	containerURL := RootUrl + containerName
+	if err := os.Remove(containerURL); err != nil && !os.IsNotExist(err) {
+		logrus.Warnf("删除目录失败 %s error %v", containerURL, err)
 	}
+	return nil
 }
 
-func DeleteMountPoint(rootURL string, mntURL string) {
-	cmd := exec.Command("umount", mntURL)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		logrus.Errorf("%v", err)
+func DeleteMountPoint(rootURL string, mntURL string) error {
+	if IsMountPoint(mntURL) {
+		cmd := exec.Command("umount", mntURL)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			logrus.Errorf("卸载 %s 失败 %v", mntURL, err)
+			return fmt.Errorf("卸载 %s 失败 %v", mntURL, err)
+		}
 	}
 	if err := os.RemoveAll(mntURL); err != nil {
 		logrus.Errorf("删除目录失败 %s error %v", mntURL, err)
+		return err
 	}
+	return nil
 }
@@ -0,0 +1,27 @@
+package fs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindOverlayLowerDir(t *testing.T) {
+	mountInfo := filepath.Join(t.TempDir(), "mountinfo")
+	content := "22 1 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:5 - proc proc rw\n" +
+		"40 25 0:35 / /opt/yocker/c1/merged rw,relatime shared:20 - overlay overlay rw,lowerdir=/opt/yocker/busybox,upperdir=/opt/yocker/c1/upper,workdir=/opt/yocker/c1/work\n" +
+		"41 25 0:36 / /opt/yocker/c2/merged rw,relatime shared:21 - tmpfs tmpfs rw,lowerdir=/x\n"
+	if err := ioutil.WriteFile(mountInfo, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for mntURL, want := range map[string]string{
+		"/opt/yocker/c1/merged/": "/opt/yocker/busybox",
+		// 不是overlay的挂载和没有挂载的目录
+		"/opt/yocker/c2/merged/": "",
+		"/opt/yocker/c3/merged/": "",
+	} {
+		if got := findOverlayLowerDir(mountInfo, mntURL); got != want {
+			t.Errorf("%s 的lowerdir为 %q 期望 %q", mntURL, got, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package image
+
+import (
+	"archive/tar"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// layer的tar包中用.wh.<name>表示删除了下层的文件 用.wh..wh..opq表示目录替换了下层的同名目录
+// overlay中分别对应0/0的字符设备和trusted.overlay.opaque=y的目录
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+	opaqueXattr    = "trusted.overlay.opaque"
+)
+
+// 把overlay的upper目录打包为layer 删除和替换的文件转换为whiteout
+func writeLayer(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	// 硬链接只保存第一个文件 其他的作为链接
+	inodes := make(map[uint64]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == "." {
+			return err
+		}
+		stat, _ := info.Sys().(*syscall.Stat_t)
+		if info.Mode()&os.ModeCharDevice != 0 && stat != nil && stat.Rdev == 0 {
+			return writeWhiteout(tw, filepath.Join(filepath.Dir(name), whiteoutPrefix+info.Name()))
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		// 宿主机上的用户名在容器中没有意义
+		hdr.Uname, hdr.Gname = "", ""
+		if info.Mode().IsRegular() && stat != nil && stat.Nlink > 1 {
+			if target, ok := inodes[stat.Ino]; ok {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = target
+				hdr.Size = 0
+			} else {
+				inodes[stat.Ino] = name
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := copyFile(tw, path); err != nil {
+				return err
+			}
+		}
+		if info.IsDir() && isOpaque(path) {
+			return writeWhiteout(tw, filepath.Join(name, whiteoutOpaque))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeWhiteout(tw *tar.Writer, name string) error {
+	return tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0600,
+	})
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func isOpaque(path string) bool {
+	buf := make([]byte, 1)
+	n, err := unix.Lgetxattr(path, opaqueXattr, buf)
+	return err == nil && n == 1 && buf[0] == 'y'
+}
+
+// 把layer的tar包解压到root whiteout转换为overlay的格式 解压后的目录可以直接作为lowerdir
+func applyLayer(r io.Reader, root string) error {
+	tr := tar.NewReader(r)
+	// 目录中的文件都解压后才能设置目录的修改时间
+	var dirs []*tar.Header
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取layer失败 %v", err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name := filepath.Clean("/" + hdr.Name)[1:]
+		if name == "" {
+			continue
+		}
+		path, err := securePath(root, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		base := filepath.Base(path)
+		if base == whiteoutOpaque {
+			if err := unix.Setxattr(filepath.Dir(path), opaqueXattr, []byte("y"), 0); err != nil {
+				return fmt.Errorf("设置opaque目录失败 %s %v", name, err)
+			}
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			target := filepath.Join(filepath.Dir(path), strings.TrimPrefix(base, whiteoutPrefix))
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			if err := unix.Mknod(target, unix.S_IFCHR, 0); err != nil {
+				return fmt.Errorf("创建whiteout失败 %s %v", name, err)
+			}
+			continue
+		}
+
+		// tar包中后面的条目覆盖前面的同名文件
+		if fi, err := os.Lstat(path); err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+		if err := createEntry(tr, hdr, root, path); err != nil {
+			return fmt.Errorf("解压 %s 失败 %v", name, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			dirs = append(dirs, hdr)
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path := filepath.Join(root, filepath.Clean("/"+dirs[i].Name))
+		os.Chtimes(path, dirs[i].ModTime, dirs[i].ModTime)
+	}
+	return nil
+}
+
+func createEntry(tr *tar.Reader, hdr *tar.Header, root, path string) error {
+	mode := uint32(hdr.Mode & 07777)
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(file, tr); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := os.Symlink(hdr.Linkname, path); err != nil {
+			return err
+		}
+		return os.Lchown(path, hdr.Uid, hdr.Gid)
+	case tar.TypeLink:
+		target, err := securePath(root, filepath.Clean("/" + hdr.Linkname)[1:])
+		if err != nil {
+			return err
+		}
+		return os.Link(target, path)
+	case tar.TypeChar:
+		mode |= unix.S_IFCHR
+	case tar.TypeBlock:
+		mode |= unix.S_IFBLK
+	case tar.TypeFifo:
+		mode |= unix.S_IFIFO
+	default:
+		logrus.Warnf("跳过不支持的文件类型 %s %c", hdr.Name, hdr.Typeflag)
+		return nil
+	}
+	if mode&unix.S_IFMT != 0 {
+		if err := unix.Mknod(path, mode, int(unix.Mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor)))); err != nil {
+			return err
+		}
+	}
+	// chown会清除setuid位 所以在chmod之前
+	if err := os.Lchown(path, hdr.Uid, hdr.Gid); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, hdr.FileInfo().Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeDir {
+		return os.Chtimes(path, hdr.ModTime, hdr.ModTime)
+	}
+	return nil
+}
+
+// 返回name在root下的路径 路径中不能有符号链接 防止通过符号链接写到root之外
+func securePath(root, name string) (string, error) {
+	path := root
+	parts := strings.Split(name, "/")
+	for i, part := range parts[:len(parts)-1] {
+		path = filepath.Join(path, part)
+		fi, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return filepath.Join(root, name), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("layer中的路径 %s 经过符号链接 %s", name, strings.Join(parts[:i+1], "/"))
+		}
+	}
+	return filepath.Join(root, name), nil
+}
@@ -0,0 +1,127 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// StoreRoot 镜像仓库的根目录 每个镜像一个以id命名的目录 目录中保存镜像的配置
+var StoreRoot = "/opt/yocker/images/"
+
+const (
+	// 镜像目录中的配置文件
+	configFileName = "config.json"
+	idPrefix       = "sha256:"
+	shortIDLength  = 12
+)
+
+// Image 镜像仓库中的一个镜像 同一个镜像可以有多个tag
+type Image struct {
+	// 镜像配置的sha256 如sha256:<hex>
+	ID       string   `json:"id"`
+	RepoTags []string `json:"repo_tags"`
+	// 所有layer的tar包的大小
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+	// commit时容器使用的镜像的id
+	Parent string `json:"parent,omitempty"`
+	// 镜像的layer 从下到上排列 每一层是未压缩的tar包的sha256
+	Layers []string `json:"layers"`
+}
+
+// Config 按OCI镜像配置的格式保存在镜像目录中 这里只定义yocker用到的字段
+// 导入的镜像保存原始的配置 镜像id和docker中的相同
+type Config struct {
+	Created      time.Time `json:"created"`
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	RootFS       RootFS    `json:"rootfs"`
+}
+
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// Hex 去掉sha256:前缀的id
+func (img *Image) Hex() string {
+	return strings.TrimPrefix(img.ID, idPrefix)
+}
+
+// ShortID ps和images中展示的12位id
+func (img *Image) ShortID() string {
+	return ShortID(img.ID)
+}
+
+// ShortID 取id的前12位
+func ShortID(id string) string {
+	id = strings.TrimPrefix(id, idPrefix)
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
+	}
+	return id
+}
+
+func (img *Image) Dir() string {
+	return StoreRoot + img.Hex() + "/"
+}
+
+func (img *Image) ConfigPath() string {
+	return img.Dir() + configFileName
+}
+
+// LowerDir 容器overlay的lowerdir 上层的layer在前 如l3:l2:l1
+// overlay的挂载参数最长为一页 layer过多时挂载会失败
+func (img *Image) LowerDir() string {
+	dirs := make([]string, 0, len(img.Layers))
+	for i := len(img.Layers) - 1; i >= 0; i-- {
+		dirs = append(dirs, LayerDir(img.Layers[i]))
+	}
+	return strings.Join(dirs, ":")
+}
+
+// 检查镜像的layer是否都已经解压
+func (img *Image) checkLayers() error {
+	if len(img.Layers) == 0 {
+		return fmt.Errorf("镜像 %s 没有layer", img.ShortID())
+	}
+	for _, diffID := range img.Layers {
+		if !layerExists(diffID) {
+			return fmt.Errorf("镜像 %s 的layer %s 不存在", img.ShortID(), ShortID(diffID))
+		}
+	}
+	return nil
+}
+
+// 生成镜像的配置 配置的sha256作为镜像id
+func newConfig(layers []string) ([]byte, error) {
+	config := &Config{
+		Created:      time.Now().UTC(),
+		Architecture: runtime.GOARCH,
+		OS:           "linux",
+		RootFS: RootFS{
+			Type:    "layers",
+			DiffIDs: layers,
+		},
+	}
+	return json.Marshal(config)
+}
+
+func digestBytes(content []byte) string {
+	sum := sha256.Sum256(content)
+	return idPrefix + hex.EncodeToString(sum[:])
+}
+
+func writeConfig(img *Image, content []byte) error {
+	if err := os.MkdirAll(img.Dir(), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(img.ConfigPath(), content, 0644)
+}
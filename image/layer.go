@@ -0,0 +1,147 @@
+package image
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// LayerRoot 按内容寻址的layer 每个layer一个以diff id命名的目录 多个镜像共用相同的layer
+var LayerRoot = "/opt/yocker/layers/"
+
+const (
+	// layer目录中未压缩的tar包和解压后的目录
+	layerTarName  = "layer.tar"
+	layerDiffName = "diff"
+)
+
+// LayerDir layer解压后的目录 作为overlay的一个lowerdir
+func LayerDir(diffID string) string {
+	return LayerRoot + strings.TrimPrefix(diffID, idPrefix) + "/" + layerDiffName
+}
+
+// LayerTarPath layer未压缩的tar包 diff id是它的sha256
+func LayerTarPath(diffID string) string {
+	return LayerRoot + strings.TrimPrefix(diffID, idPrefix) + "/" + layerTarName
+}
+
+func layerExists(diffID string) bool {
+	_, err := os.Stat(LayerDir(diffID))
+	return err == nil
+}
+
+func layerSize(diffID string) int64 {
+	info, err := os.Stat(LayerTarPath(diffID))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// 保存layer的tar包并解压 支持gzip压缩的tar包 返回未压缩的tar包的sha256
+// 需要在持有镜像锁时调用 否则可能被同时执行的rmi当作没有使用的layer删除
+func createLayer(r io.Reader) (string, error) {
+	if err := os.MkdirAll(LayerRoot, 0755); err != nil {
+		return "", fmt.Errorf("创建layer目录失败 %v", err)
+	}
+	reader, err := decompress(r)
+	if err != nil {
+		return "", err
+	}
+	tmpFile, err := ioutil.TempFile(LayerRoot, "layer-*.tar")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmpFile, hash), reader)
+	tmpFile.Close()
+	if err != nil {
+		return "", fmt.Errorf("保存layer失败 %v", err)
+	}
+	diffID := idPrefix + hex.EncodeToString(hash.Sum(nil))
+	if layerExists(diffID) {
+		return diffID, nil
+	}
+
+	layerDir := LayerRoot + strings.TrimPrefix(diffID, idPrefix) + "/"
+	if err := os.MkdirAll(layerDir, 0755); err != nil {
+		return "", err
+	}
+	// 先解压到临时目录 解压完成后再rename 中途失败不会留下不完整的layer
+	tmpDir, err := ioutil.TempDir(layerDir, layerDiffName+"-")
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(tmpFile.Name())
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	err = applyLayer(file, tmpDir)
+	file.Close()
+	if err == nil {
+		err = os.Chmod(tmpDir, 0755)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), LayerTarPath(diffID))
+	}
+	if err == nil {
+		err = os.Rename(tmpDir, LayerDir(diffID))
+	}
+	if err != nil {
+		os.RemoveAll(layerDir)
+		return "", fmt.Errorf("解压layer失败 %v", err)
+	}
+	return diffID, nil
+}
+
+// 把目录打包为layer 用于commit容器的upper目录
+func createLayerFromDir(dir string) (string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeLayer(dir, pw))
+	}()
+	diffID, err := createLayer(pr)
+	pr.CloseWithError(io.ErrClosedPipe)
+	return diffID, err
+}
+
+// 根据开头的magic判断是否是gzip压缩的
+func decompress(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
+
+// 删除没有被任何镜像使用的layer
+func (idx *Index) pruneLayers() {
+	used := make(map[string]bool)
+	for _, img := range idx.Images {
+		for _, diffID := range img.Layers {
+			used[strings.TrimPrefix(diffID, idPrefix)] = true
+		}
+	}
+	entries, err := ioutil.ReadDir(LayerRoot)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !isHex(entry.Name()) || used[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(LayerRoot + entry.Name()); err != nil {
+			logrus.Warnf("删除layer失败 %s %v", entry.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"yocker/oci"
+)
+
+// docker save生成的tar包中的manifest.json
+const dockerManifestFile = "manifest.json"
+
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// LoadedImage 导入的镜像 Ref为空时镜像没有tag
+type LoadedImage struct {
+	Image *Image
+	Ref   string
+}
+
+// LoadArchive 导入docker save生成的tar包或OCI镜像布局的tar包 tar包和layer都支持gzip压缩
+func LoadArchive(r io.Reader) ([]LoadedImage, error) {
+	if err := os.MkdirAll(StoreRoot, 0755); err != nil {
+		return nil, fmt.Errorf("创建镜像目录失败 %v", err)
+	}
+	dir, err := ioutil.TempDir(StoreRoot, "load-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	// 从标准输入读取时tar不会自动识别压缩格式
+	reader, err := decompress(r)
+	if err != nil {
+		return nil, fmt.Errorf("解压镜像包失败 %v", err)
+	}
+	cmd := exec.Command("tar", "-xf", "-", "-C", dir)
+	cmd.Stdin = reader
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("解压镜像包失败 %v %s", err, strings.TrimSpace(string(output)))
+	}
+
+	var loaded []LoadedImage
+	err = Update(func(idx *Index) error {
+		// docker 25之后的tar包同时是OCI镜像布局 manifest.json中的镜像名更简短 优先使用
+		var loadErr error
+		if _, err := os.Stat(filepath.Join(dir, dockerManifestFile)); err == nil {
+			loaded, loadErr = idx.loadDockerArchive(dir)
+		} else if _, err := os.Stat(filepath.Join(dir, oci.ImageLayoutFile)); err == nil {
+			loaded, loadErr = idx.loadOCILayout(dir)
+		} else {
+			loadErr = fmt.Errorf("既不是OCI镜像布局也不是docker save生成的tar包")
+		}
+		return loadErr
+	})
+	return loaded, err
+}
+
+// manifest.json中每一项是一个镜像 路径相对于tar包的根目录
+func (idx *Index) loadDockerArchive(dir string) ([]LoadedImage, error) {
+	content, err := readArchiveFile(dir, dockerManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifests []dockerManifest
+	if err := json.Unmarshal(content, &manifests); err != nil {
+		return nil, fmt.Errorf("解析manifest.json失败 %v", err)
+	}
+	var loaded []LoadedImage
+	for _, manifest := range manifests {
+		config, err := readArchiveFile(dir, manifest.Config)
+		if err != nil {
+			return nil, err
+		}
+		var layers []io.ReadCloser
+		for _, layer := range manifest.Layers {
+			file, err := openArchiveFile(dir, layer)
+			if err != nil {
+				closeAll(layers)
+				return nil, err
+			}
+			layers = append(layers, file)
+		}
+		img, err := idx.loadImage(config, layers)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, idx.tagLoaded(img, manifest.RepoTags)...)
+	}
+	return loaded, nil
+}
+
+// index.json中的每一项是一个镜像的manifest或者多平台镜像的index
+func (idx *Index) loadOCILayout(dir string) ([]LoadedImage, error) {
+	content, err := readArchiveFile(dir, oci.ImageIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	var index oci.ImageIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("解析index.json失败 %v", err)
+	}
+	var loaded []LoadedImage
+	for _, desc := range index.Manifests {
+		img, err := idx.loadDescriptor(dir, desc)
+		if err != nil {
+			return nil, err
+		}
+		var refs []string
+		if ref := refName(desc.Annotations); ref != "" {
+			refs = append(refs, ref)
+		}
+		loaded = append(loaded, idx.tagLoaded(img, refs)...)
+	}
+	return loaded, nil
+}
+
+func (idx *Index) loadDescriptor(dir string, desc oci.Descriptor) (*Image, error) {
+	content, err := readBlob(dir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	switch desc.MediaType {
+	case oci.MediaTypeImageIndex, oci.MediaTypeDockerManifestList:
+		var index oci.ImageIndex
+		if err := json.Unmarshal(content, &index); err != nil {
+			return nil, fmt.Errorf("解析镜像index失败 %v", err)
+		}
+		// 多平台镜像只导入当前平台的
+		for _, manifest := range index.Manifests {
+			if manifest.Platform == nil || (manifest.Platform.OS == "linux" && manifest.Platform.Architecture == runtime.GOARCH) {
+				return idx.loadDescriptor(dir, manifest)
+			}
+		}
+		return nil, fmt.Errorf("镜像中没有linux/%s平台的manifest", runtime.GOARCH)
+	case oci.MediaTypeImageManifest, oci.MediaTypeDockerManifest:
+	default:
+		return nil, fmt.Errorf("不支持的media type %s", desc.MediaType)
+	}
+
+	var manifest oci.Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("解析镜像manifest失败 %v", err)
+	}
+	config, err := readBlob(dir, manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var layers []io.ReadCloser
+	for _, layer := range manifest.Layers {
+		var file io.ReadCloser
+		if layer.MediaType == oci.MediaTypeLayerZstd {
+			err = fmt.Errorf("不支持zstd压缩的layer %s", layer.Digest)
+		} else {
+			file, err = openBlob(dir, layer.Digest)
+		}
+		if err != nil {
+			closeAll(layers)
+			return nil, err
+		}
+		layers = append(layers, file)
+	}
+	return idx.loadImage(config, layers)
+}
+
+// 依次导入layer 检查layer和镜像配置中的diff_ids一致后添加镜像
+func (idx *Index) loadImage(content []byte, layers []io.ReadCloser) (*Image, error) {
+	defer closeAll(layers)
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("解析镜像配置失败 %v", err)
+	}
+	if len(config.RootFS.DiffIDs) != len(layers) {
+		return nil, fmt.Errorf("镜像配置中有%d个layer 实际有%d个", len(config.RootFS.DiffIDs), len(layers))
+	}
+	for i, layer := range layers {
+		diffID, err := createLayer(layer)
+		if err != nil {
+			return nil, err
+		}
+		if diffID != config.RootFS.DiffIDs[i] {
+			return nil, fmt.Errorf("layer %s 和镜像配置中的 %s 不一致", ShortID(diffID), ShortID(config.RootFS.DiffIDs[i]))
+		}
+	}
+	return idx.addImage(content, "", "")
+}
+
+func (idx *Index) tagLoaded(img *Image, refs []string) []LoadedImage {
+	var loaded []LoadedImage
+	for _, ref := range refs {
+		normalized, err := NormalizeReference(ref)
+		if err != nil {
+			logrus.Warnf("忽略无效的镜像名 %s", ref)
+			continue
+		}
+		idx.Tag(img, normalized)
+		loaded = append(loaded, LoadedImage{Image: img, Ref: normalized})
+	}
+	if len(loaded) == 0 {
+		loaded = append(loaded, LoadedImage{Image: img})
+	}
+	return loaded
+}
+
+// 优先使用containerd写入的完整镜像名 ref.name只有tag时无法确定镜像名
+func refName(annotations map[string]string) string {
+	if name := annotations[oci.AnnotationContainerdName]; name != "" {
+		// docker.io/library/busybox:latest -> busybox:latest
+		for _, prefix := range []string{"docker.io/library/", "docker.io/"} {
+			if strings.HasPrefix(name, prefix) {
+				return strings.TrimPrefix(name, prefix)
+			}
+		}
+		return name
+	}
+	name := annotations[oci.AnnotationRefName]
+	if name != "" && !strings.ContainsAny(name, ":/") {
+		logrus.Warnf("ref.name %s 中没有镜像名 导入的镜像没有tag", name)
+		return ""
+	}
+	return name
+}
+
+// 读取blob并检查digest
+func readBlob(dir, digest string) ([]byte, error) {
+	file, err := openBlob(dir, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if digestBytes(content) != digest {
+		return nil, fmt.Errorf("blob %s 的内容和digest不一致", digest)
+	}
+	return content, nil
+}
+
+// blob保存在blobs/sha256/<hex> layer的内容由diff id检查
+func openBlob(dir, digest string) (*os.File, error) {
+	hex := strings.TrimPrefix(digest, idPrefix)
+	if !strings.HasPrefix(digest, idPrefix) || len(hex) != 64 || !isHex(hex) {
+		return nil, fmt.Errorf("不支持的digest %s", digest)
+	}
+	return openArchiveFile(dir, filepath.Join(oci.BlobsDir, "sha256", hex))
+}
+
+func readArchiveFile(dir, name string) ([]byte, error) {
+	file, err := openArchiveFile(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+// 打开镜像包中的文件 文件必须是镜像包中的普通文件 不能通过符号链接读取镜像包之外的文件
+func openArchiveFile(dir, name string) (*os.File, error) {
+	name = filepath.Clean("/" + name)[1:]
+	path, err := securePath(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("镜像包中没有 %s", name)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("镜像包中的 %s 不是普通文件", name)
+	}
+	return os.Open(path)
+}
+
+func closeAll(files []io.ReadCloser) {
+	for _, file := range files {
+		file.Close()
+	}
+}
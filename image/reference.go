@@ -0,0 +1,33 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTag 没有指定tag时使用
+const DefaultTag = "latest"
+
+// ParseReference 把name[:tag]拆分为name和tag 仓库地址中的端口号不会被当作tag 如localhost:5000/busybox
+func ParseReference(ref string) (string, string, error) {
+	if ref == "" {
+		return "", "", fmt.Errorf("镜像名为空")
+	}
+	name, tag := ref, DefaultTag
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name, tag = ref[:i], ref[i+1:]
+	}
+	if name == "" || tag == "" {
+		return "", "", fmt.Errorf("无效的镜像名 %s", ref)
+	}
+	return name, tag, nil
+}
+
+// NormalizeReference 补全tag 如busybox -> busybox:latest
+func NormalizeReference(ref string) (string, error) {
+	name, tag, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	return name + ":" + tag, nil
+}
@@ -0,0 +1,215 @@
+package image
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"yocker/oci"
+)
+
+// SaveArchive 把镜像按OCI镜像布局打包写入w 同时写入manifest.json 旧版本的docker load也可以导入
+// 按tag指定镜像时只保存这个tag 按id指定时保存镜像所有的tag
+func SaveArchive(refs []string, w io.Writer) error {
+	return View(func(idx *Index) error {
+		var images []*Image
+		tags := make(map[string][]string)
+		for _, ref := range refs {
+			img, err := idx.Lookup(ref)
+			if err != nil {
+				return err
+			}
+			if _, ok := tags[img.ID]; !ok {
+				images = append(images, img)
+				tags[img.ID] = []string{}
+			}
+			if img.HasTag(ref) {
+				normalized, _ := NormalizeReference(ref)
+				tags[img.ID] = appendUnique(tags[img.ID], normalized)
+			} else {
+				for _, tag := range img.RepoTags {
+					tags[img.ID] = appendUnique(tags[img.ID], tag)
+				}
+			}
+		}
+		archive := newLayoutWriter(w)
+		for _, img := range images {
+			if err := archive.addImage(img, tags[img.ID]); err != nil {
+				return err
+			}
+		}
+		return archive.close()
+	})
+}
+
+// 按OCI镜像布局写tar包 多个镜像共用的blob只写一次
+type layoutWriter struct {
+	tw        *tar.Writer
+	blobs     map[string]bool
+	index     oci.ImageIndex
+	manifests []dockerManifest
+}
+
+func newLayoutWriter(w io.Writer) *layoutWriter {
+	return &layoutWriter{
+		tw:    tar.NewWriter(w),
+		blobs: make(map[string]bool),
+		index: oci.ImageIndex{
+			SchemaVersion: 2,
+			MediaType:     oci.MediaTypeImageIndex,
+			Manifests:     []oci.Descriptor{},
+		},
+	}
+}
+
+func (l *layoutWriter) addImage(img *Image, tags []string) error {
+	config, err := ioutil.ReadFile(img.ConfigPath())
+	if err != nil {
+		return fmt.Errorf("读取镜像配置失败 %v", err)
+	}
+	configDesc, err := l.writeBlob(oci.MediaTypeImageConfig, config)
+	if err != nil {
+		return err
+	}
+	manifest := oci.Manifest{
+		SchemaVersion: 2,
+		MediaType:     oci.MediaTypeImageManifest,
+		Config:        configDesc,
+		Layers:        []oci.Descriptor{},
+	}
+	dockerLayers := []string{}
+	// layer保存的是未压缩的tar包 digest就是diff id
+	for _, diffID := range img.Layers {
+		desc, err := l.writeLayer(diffID)
+		if err != nil {
+			return err
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+		dockerLayers = append(dockerLayers, blobPath(diffID))
+	}
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	manifestDesc, err := l.writeBlob(oci.MediaTypeImageManifest, content)
+	if err != nil {
+		return err
+	}
+
+	// 每个tag一项 没有tag时index中的这一项没有名字
+	if len(tags) == 0 {
+		l.index.Manifests = append(l.index.Manifests, manifestDesc)
+	}
+	for _, tag := range tags {
+		desc := manifestDesc
+		desc.Annotations = map[string]string{
+			oci.AnnotationContainerdName: tag,
+			oci.AnnotationRefName:        tag[strings.LastIndex(tag, ":")+1:],
+		}
+		l.index.Manifests = append(l.index.Manifests, desc)
+	}
+	l.manifests = append(l.manifests, dockerManifest{
+		Config:   blobPath(configDesc.Digest),
+		RepoTags: tags,
+		Layers:   dockerLayers,
+	})
+	return nil
+}
+
+func (l *layoutWriter) writeBlob(mediaType string, content []byte) (oci.Descriptor, error) {
+	desc := oci.Descriptor{
+		MediaType: mediaType,
+		Digest:    digestBytes(content),
+		Size:      int64(len(content)),
+	}
+	if l.blobs[desc.Digest] {
+		return desc, nil
+	}
+	l.blobs[desc.Digest] = true
+	return desc, l.writeFile(blobPath(desc.Digest), content)
+}
+
+func (l *layoutWriter) writeLayer(diffID string) (oci.Descriptor, error) {
+	file, err := os.Open(LayerTarPath(diffID))
+	if err != nil {
+		return oci.Descriptor{}, fmt.Errorf("读取layer失败 %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return oci.Descriptor{}, err
+	}
+	desc := oci.Descriptor{
+		MediaType: oci.MediaTypeLayer,
+		Digest:    diffID,
+		Size:      info.Size(),
+	}
+	if l.blobs[diffID] {
+		return desc, nil
+	}
+	l.blobs[diffID] = true
+	if err := l.tw.WriteHeader(fileHeader(blobPath(diffID), info.Size())); err != nil {
+		return desc, err
+	}
+	_, err = io.Copy(l.tw, file)
+	return desc, err
+}
+
+// 最后写入oci-layout index.json和manifest.json
+func (l *layoutWriter) close() error {
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{oci.ImageLayoutFile, oci.ImageLayout{Version: oci.ImageLayoutVersion}},
+		{oci.ImageIndexFile, l.index},
+		{dockerManifestFile, l.manifests},
+	}
+	for _, file := range files {
+		content, err := json.Marshal(file.value)
+		if err != nil {
+			return err
+		}
+		if err := l.writeFile(file.name, content); err != nil {
+			return err
+		}
+	}
+	return l.tw.Close()
+}
+
+func (l *layoutWriter) writeFile(name string, content []byte) error {
+	if err := l.tw.WriteHeader(fileHeader(name, int64(len(content)))); err != nil {
+		return err
+	}
+	_, err := l.tw.Write(content)
+	return err
+}
+
+// 固定文件的修改时间 同样的镜像打出的包相同
+func fileHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  time.Unix(0, 0),
+	}
+}
+
+func blobPath(digest string) string {
+	return path.Join(oci.BlobsDir, "sha256", strings.TrimPrefix(digest, idPrefix))
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, item := range list {
+		if item == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,498 @@
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	indexFile = "index.json"
+	lockFile  = "index.lock"
+)
+
+// 之前的版本直接把镜像放在/opt/yocker下 <name>.tar和解压后的<name>/
+var legacyRoot = "/opt/yocker/"
+
+var ErrImageNotFound = errors.New("镜像不存在")
+
+// Index 镜像仓库的元数据 保存在StoreRoot/index.json
+type Index struct {
+	Images []*Image `json:"images"`
+	// 是否已经导入过之前版本留下的镜像 只在第一次修改仓库时导入一次
+	LegacyImported bool `json:"legacy_imported"`
+}
+
+// Load 读取镜像索引 只读取不修改仓库
+func Load() (*Index, error) {
+	var result *Index
+	err := withIndex(false, func(idx *Index) error {
+		result = idx
+		return nil
+	})
+	return result, err
+}
+
+// Update 加锁后读取索引 fn修改后写回 多个yocker进程同时修改时不会丢失
+// 第一次修改仓库时导入之前版本留下的镜像
+func Update(fn func(idx *Index) error) error {
+	return withIndex(true, func(idx *Index) error {
+		if !idx.LegacyImported {
+			idx.importLegacy()
+			idx.LegacyImported = true
+		}
+		return fn(idx)
+	})
+}
+
+// View 加共享锁后读取索引 fn执行期间镜像和layer不会被删除
+func View(fn func(idx *Index) error) error {
+	return withIndex(false, fn)
+}
+
+// save为true时加排他锁 fn执行后写回索引 否则加共享锁 多个只读的命令可以同时执行
+func withIndex(save bool, fn func(idx *Index) error) error {
+	if err := os.MkdirAll(StoreRoot, 0755); err != nil {
+		return fmt.Errorf("创建镜像目录失败 %v", err)
+	}
+	lock, err := os.OpenFile(StoreRoot+lockFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("打开镜像锁失败 %v", err)
+	}
+	defer lock.Close()
+	how := syscall.LOCK_SH
+	if save {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(lock.Fd()), how); err != nil {
+		return fmt.Errorf("获取镜像锁失败 %v", err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+	if err := fn(idx); err != nil {
+		return err
+	}
+	if !save {
+		return nil
+	}
+	return idx.write()
+}
+
+func readIndex() (*Index, error) {
+	idx := &Index{}
+	content, err := ioutil.ReadFile(StoreRoot + indexFile)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取镜像索引失败 %v", err)
+	}
+	if err := json.Unmarshal(content, idx); err != nil {
+		return nil, fmt.Errorf("解析镜像索引失败 %v", err)
+	}
+	return idx, nil
+}
+
+// 先写临时文件再rename 写到一半退出时不会破坏索引
+func (idx *Index) write() error {
+	content, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile := StoreRoot + indexFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, content, 0644); err != nil {
+		return fmt.Errorf("写入镜像索引失败 %v", err)
+	}
+	if err := os.Rename(tmpFile, StoreRoot+indexFile); err != nil {
+		return fmt.Errorf("写入镜像索引失败 %v", err)
+	}
+	return nil
+}
+
+// Get 按完整的id查找镜像
+func (idx *Index) Get(id string) *Image {
+	for _, img := range idx.Images {
+		if img.ID == id {
+			return img
+		}
+	}
+	return nil
+}
+
+// Lookup 先按name:tag查找 找不到时按id或id的前缀查找
+func (idx *Index) Lookup(ref string) (*Image, error) {
+	if normalized, err := NormalizeReference(ref); err == nil {
+		for _, img := range idx.Images {
+			for _, tag := range img.RepoTags {
+				if tag == normalized {
+					return img, nil
+				}
+			}
+		}
+	}
+	prefix := strings.TrimPrefix(ref, idPrefix)
+	if !isHex(prefix) {
+		return nil, fmt.Errorf("%w %s", ErrImageNotFound, ref)
+	}
+	var found *Image
+	for _, img := range idx.Images {
+		if strings.HasPrefix(img.Hex(), prefix) {
+			if found != nil {
+				return nil, fmt.Errorf("镜像id %s 不唯一", ref)
+			}
+			found = img
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w %s", ErrImageNotFound, ref)
+	}
+	return found, nil
+}
+
+// Create 用layers创建镜像 layer需要已经在layer目录中 ref不为空时给镜像打上tag
+func (idx *Index) Create(layers []string, parent, ref string) (*Image, error) {
+	content, err := newConfig(layers)
+	if err != nil {
+		return nil, err
+	}
+	return idx.addImage(content, parent, ref)
+}
+
+// 按镜像配置添加镜像 配置的sha256作为镜像id 配置中的diff_ids需要都已经在layer目录中
+func (idx *Index) addImage(content []byte, parent, ref string) (*Image, error) {
+	if ref != "" {
+		normalized, err := NormalizeReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		ref = normalized
+	}
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("解析镜像配置失败 %v", err)
+	}
+	id := digestBytes(content)
+	img := idx.Get(id)
+	if img == nil {
+		img = &Image{
+			ID:       id,
+			RepoTags: []string{},
+			Created:  config.Created,
+			Parent:   parent,
+			Layers:   config.RootFS.DiffIDs,
+		}
+		if err := img.checkLayers(); err != nil {
+			return nil, err
+		}
+		for _, diffID := range img.Layers {
+			img.Size += layerSize(diffID)
+		}
+		if err := writeConfig(img, content); err != nil {
+			return nil, fmt.Errorf("保存镜像配置失败 %v", err)
+		}
+		idx.Images = append(idx.Images, img)
+	}
+	if ref != "" {
+		idx.Tag(img, ref)
+	}
+	return img, nil
+}
+
+// Import 把一个rootfs的tar包导入为只有一层的镜像
+func (idx *Index) Import(r io.Reader, ref string) (*Image, error) {
+	diffID, err := createLayer(r)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Create([]string{diffID}, "", ref)
+}
+
+// Commit 把容器的upper目录作为新的layer 放在容器使用的镜像的layer之上
+// parentID为空时dir是完整的rootfs 如bundle运行的容器
+func (idx *Index) Commit(dir, parentID, ref string) (*Image, error) {
+	var layers []string
+	if parentID != "" {
+		parent := idx.Get(parentID)
+		if parent == nil {
+			return nil, fmt.Errorf("%w %s", ErrImageNotFound, ShortID(parentID))
+		}
+		layers = append(layers, parent.Layers...)
+	}
+	diffID, err := createLayerFromDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Create(append(layers, diffID), parentID, ref)
+}
+
+// Tag 把ref指向img 原来使用这个ref的镜像会失去这个tag
+func (idx *Index) Tag(img *Image, ref string) {
+	for _, other := range idx.Images {
+		tags := other.RepoTags[:0]
+		for _, tag := range other.RepoTags {
+			if tag != ref {
+				tags = append(tags, tag)
+			}
+		}
+		other.RepoTags = tags
+	}
+	img.RepoTags = append(img.RepoTags, ref)
+}
+
+// HasTag 镜像是否有这个tag ref没有tag时补全为latest
+func (img *Image) HasTag(ref string) bool {
+	normalized, err := NormalizeReference(ref)
+	if err != nil {
+		return false
+	}
+	for _, tag := range img.RepoTags {
+		if tag == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// Untag 去掉镜像的一个tag 镜像本身仍然保留
+func (idx *Index) Untag(img *Image, ref string) {
+	normalized, err := NormalizeReference(ref)
+	if err != nil {
+		return
+	}
+	tags := img.RepoTags[:0]
+	for _, tag := range img.RepoTags {
+		if tag != normalized {
+			tags = append(tags, tag)
+		}
+	}
+	img.RepoTags = tags
+	removeLegacyFiles(normalized)
+}
+
+// Remove 删除镜像的配置和索引中的记录 没有其他镜像使用的layer也会被删除
+func (idx *Index) Remove(img *Image) error {
+	if err := os.RemoveAll(img.Dir()); err != nil {
+		return fmt.Errorf("删除镜像目录失败 %v", err)
+	}
+	images := idx.Images[:0]
+	for _, other := range idx.Images {
+		if other.ID != img.ID {
+			images = append(images, other)
+		}
+	}
+	idx.Images = images
+	idx.pruneLayers()
+	for _, tag := range img.RepoTags {
+		removeLegacyFiles(tag)
+	}
+	return nil
+}
+
+// Resolve 查找镜像并检查layer是否完整 返回的镜像可以直接作为容器的只读层
+// 只按索引判断镜像是否存在 之前版本的镜像在第一次修改仓库时已经导入
+func Resolve(ref string) (*Image, error) {
+	idx, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	img, err := idx.Lookup(ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := img.checkLayers(); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// 导入/opt/yocker下所有的<name>.tar和解压在<name>/下的镜像 导入失败的保留在原处
+func (idx *Index) importLegacy() {
+	entries, err := ioutil.ReadDir(legacyRoot)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() && legacyImageDir(name) != "" {
+			names = appendUnique(names, name)
+		} else if !entry.IsDir() && strings.HasSuffix(name, ".tar") {
+			names = appendUnique(names, strings.TrimSuffix(name, ".tar"))
+		}
+	}
+	for _, name := range names {
+		if !isLegacyName(name) {
+			continue
+		}
+		if _, err := idx.Lookup(name); err == nil {
+			continue
+		}
+		img, err := idx.importLegacyImage(name)
+		if err != nil {
+			logrus.Warnf("导入镜像 %s 失败 %v", name, err)
+			continue
+		}
+		logrus.Infof("导入镜像 %s %s", name, img.ShortID())
+	}
+}
+
+// 按之前版本的方式查找镜像 先找<name>.tar 再找解压后的<name>/目录
+// 导入后移动到imported/下 之后不会再被当成镜像导入
+func (idx *Index) importLegacyImage(ref string) (*Image, error) {
+	name, _, _ := ParseReference(ref)
+	var img *Image
+	var err error
+	tarPath := legacyRoot + name + ".tar"
+	if _, statErr := os.Stat(tarPath); statErr == nil {
+		img, err = idx.importFile(tarPath, ref)
+	} else if dir := legacyImageDir(name); dir != "" {
+		img, err = idx.importLegacyDir(dir, ref)
+	} else {
+		err = fmt.Errorf("%w %s", ErrImageNotFound, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := backupLegacyFiles(name); err != nil {
+		logrus.Warnf("移动已经导入的镜像 %s 失败 %v", name, err)
+	}
+	return img, nil
+}
+
+func (idx *Index) importFile(tarPath, ref string) (*Image, error) {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return idx.Import(file, ref)
+}
+
+// 已经导入的之前版本的镜像保存在这里 不会再被导入
+func legacyBackupRoot() string {
+	return legacyRoot + "imported/"
+}
+
+// 把<name>.tar和<name>/中镜像的内容移动到imported/下 同名容器的读写层留在原处
+func backupLegacyFiles(name string) error {
+	backup := legacyBackupRoot() + name
+	tarPath := legacyRoot + name + ".tar"
+	if _, err := os.Stat(tarPath); err == nil {
+		if err := os.MkdirAll(legacyBackupRoot(), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(tarPath, backup+".tar"); err != nil {
+			return err
+		}
+	}
+	dir := legacyImageDir(name)
+	if dir == "" {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(backup, 0755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if isWorkspaceDir(entry.Name()) {
+			continue
+		}
+		if err := os.Rename(filepath.Join(dir, entry.Name()), filepath.Join(backup, entry.Name())); err != nil {
+			return err
+		}
+	}
+	// 只在没有容器读写层时删除目录
+	os.Remove(dir)
+	return nil
+}
+
+// 只有name:latest可能是之前版本的镜像 之前的版本中镜像名就是文件名
+func isLegacyName(ref string) bool {
+	name, tag, err := ParseReference(ref)
+	return err == nil && tag == DefaultTag && filepath.Base(name) == name
+}
+
+// 之前版本解压镜像的目录 目录中只有容器的读写层或者不存在时返回空
+func legacyImageDir(name string) string {
+	dir := legacyRoot + name
+	for _, root := range []string{StoreRoot, LayerRoot, legacyBackupRoot()} {
+		if filepath.Clean(dir) == filepath.Clean(root) {
+			return ""
+		}
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if !isWorkspaceDir(entry.Name()) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// 删除镜像时一起删除导入时移动到imported/下的<name>.tar和<name>/
+func removeLegacyFiles(ref string) {
+	if !isLegacyName(ref) {
+		return
+	}
+	name, _, _ := ParseReference(ref)
+	for _, file := range []string{name + ".tar", name} {
+		if err := os.RemoveAll(legacyBackupRoot() + file); err != nil {
+			logrus.Warnf("删除已经导入的镜像 %s 失败 %v", legacyBackupRoot()+file, err)
+		}
+	}
+}
+
+// 容器的读写层和挂载点 不属于镜像的内容
+func isWorkspaceDir(name string) bool {
+	return name == "upper" || name == "work" || name == "merged"
+}
+
+// 把目录打包后导入 同名容器的upper work merged目录也在这个目录下 打包时跳过
+func (idx *Index) importLegacyDir(dir, ref string) (*Image, error) {
+	tmpFile, err := ioutil.TempFile(StoreRoot, "import-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+	cmd := exec.Command("tar", "-cf", tmpFile.Name(), "--exclude=./upper", "--exclude=./work", "--exclude=./merged", "-C", dir, ".")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("打包 %s 失败 %v %s", dir, err, output)
+	}
+	img, err := idx.importFile(tmpFile.Name(), ref)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("导入镜像目录 %s %s", dir, img.ShortID())
+	return img, nil
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
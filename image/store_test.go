@@ -0,0 +1,138 @@
+package image
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// 把镜像仓库 layer目录和之前版本的镜像目录都放到临时目录中
+func useTestRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir() + "/"
+	storeRoot, layerRoot, oldLegacyRoot := StoreRoot, LayerRoot, legacyRoot
+	StoreRoot, LayerRoot, legacyRoot = root+"images/", root+"layers/", root
+	t.Cleanup(func() {
+		StoreRoot, LayerRoot, legacyRoot = storeRoot, layerRoot, oldLegacyRoot
+	})
+	return root
+}
+
+// 构造一个只有/bin/sh的rootfs
+func writeRootfs(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "bin", "sh"), []byte("sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeRootfsTar(t *testing.T, tarPath string) {
+	t.Helper()
+	rootfs := t.TempDir()
+	writeRootfs(t, rootfs)
+	if output, err := exec.Command("tar", "-cf", tarPath, "-C", rootfs, ".").CombinedOutput(); err != nil {
+		t.Fatalf("打包rootfs失败 %v %s", err, output)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// 删除ref对应的镜像 和rmi一样
+func removeByRef(t *testing.T, ref string) {
+	t.Helper()
+	err := Update(func(idx *Index) error {
+		img, err := idx.Lookup(ref)
+		if err != nil {
+			return err
+		}
+		return idx.Remove(img)
+	})
+	if err != nil {
+		t.Fatalf("删除镜像 %s 失败 %v", ref, err)
+	}
+}
+
+func TestRemoveLegacyTarImage(t *testing.T) {
+	root := useTestRoot(t)
+	tarPath := root + "busybox.tar"
+	writeRootfsTar(t, tarPath)
+	// 第一次修改仓库时导入tar包 导入后移动到imported/下
+	if err := Update(func(idx *Index) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve("busybox"); err != nil {
+		t.Fatalf("之前版本的镜像没有导入 %v", err)
+	}
+	if exists(tarPath) || !exists(root+"imported/busybox.tar") {
+		t.Errorf("导入后tar包没有移动到imported/下")
+	}
+
+	removeByRef(t, "busybox")
+	if exists(tarPath) || exists(root+"imported/busybox.tar") {
+		t.Errorf("删除镜像后tar包还在")
+	}
+	if _, err := Resolve("busybox"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("删除后的镜像又被导入了 %v", err)
+	}
+}
+
+func TestRemoveLegacyDirImage(t *testing.T) {
+	root := useTestRoot(t)
+	dir := root + "alpine"
+	writeRootfs(t, dir)
+	// 同名容器的读写层
+	if err := os.MkdirAll(filepath.Join(dir, "upper"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(func(idx *Index) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve("alpine"); err != nil {
+		t.Fatalf("之前版本解压的镜像没有导入 %v", err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "upper" {
+		t.Errorf("导入后目录中应该只剩容器的读写层 %v", entries)
+	}
+	if !exists(root + "imported/alpine/bin/sh") {
+		t.Errorf("导入后镜像没有移动到imported/下")
+	}
+
+	removeByRef(t, "alpine")
+	if exists(root + "imported/alpine") {
+		t.Errorf("删除镜像后imported/下的目录还在")
+	}
+	if _, err := Resolve("alpine"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("删除后的镜像又被导入了 %v", err)
+	}
+}
+
+func TestResolveOnlyUsesIndex(t *testing.T) {
+	root := useTestRoot(t)
+	if err := Update(func(idx *Index) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	// 导入过一次之后放入的tar包不会被当成镜像
+	writeRootfsTar(t, root+"busybox.tar")
+	if _, err := Resolve("busybox"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("之后放入的tar包被导入了 %v", err)
+	}
+	if err := Update(func(idx *Index) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve("busybox"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("修改仓库时又导入了tar包 %v", err)
+	}
+}
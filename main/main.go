@@ -18,13 +18,30 @@ func main() {
 		},
 		Commands: []*cli.Command{
 			command.InitCommand,
+			command.MonitorCommand,
 			command.RunCommand,
+			command.CreateCommand,
+			command.StartCommand,
 			command.CommitCommand,
+			command.ImagesCommand,
+			command.RemoveImageCommand,
+			command.LoadCommand,
+			command.ImportCommand,
+			command.SaveCommand,
 			command.ListCommand,
 			command.LogCommand,
 			command.StopCommand,
+			command.RestartCommand,
+			command.KillCommand,
+			command.PauseCommand,
+			command.UnpauseCommand,
+			command.WaitCommand,
+			command.OciSpecCommand,
 			command.RemoveCommand,
 			command.ExecCommand,
+			command.UpdateCommand,
+			command.InspectCommand,
+			command.StatsCommand,
 			command.NetworkCommand},
 	}
 	// 接受os.Args启动程序
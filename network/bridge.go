@@ -166,16 +166,12 @@ func (b *BridgeNetworkDriver) Connect(network *Network, endpoint *Endpoint) erro
 }
 
 func (b *BridgeNetworkDriver) DisConnect(network *Network, endpoint *Endpoint) error {
-	//bridgeName := network.Name
-	//br, err := netlink.LinkByName(bridgeName)
-	//if err != nil {
-	//	return err
-	//}
-	//err = netlink.LinkDel(&endpoint.Device)
-	//if err != nil {
-	//	return err
-	//}
-	return nil
+	// 容器的网络命名空间销毁时veth会被一起删除 这里只处理还残留的情况
+	veth, err := netlink.LinkByName(endpoint.Device.Name)
+	if err != nil {
+		return nil
+	}
+	return netlink.LinkDel(veth)
 }
 
 var _ NetworkDriver = new(BridgeNetworkDriver)
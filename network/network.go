@@ -45,12 +45,17 @@ func Connect(networkName string, cinfo *container.ContainerInfo) error {
 		return fmt.Errorf("没有该网络 %s", networkName)
 	}
 
-	ip, err := ipAllocator.Allocate(network.IpRange)
-
-	if err != nil {
-		logrus.Errorf("获取可用ip失败 %v", err)
-		return err
+	// 重启的容器继续使用之前分配的ip 这个ip没有被释放过
+	ip := net.ParseIP(cinfo.IPAddress)
+	if ip == nil || !network.IpRange.Contains(ip) {
+		var err error
+		ip, err = ipAllocator.Allocate(network.IpRange)
+		if err != nil {
+			logrus.Errorf("获取可用ip失败 %v", err)
+			return err
+		}
 	}
+	cinfo.IPAddress = ip.String()
 	// 创建网络端点
 	ep := &Endpoint{
 		ID:          fmt.Sprintf("%s-%s", cinfo.Id, networkName),
@@ -59,7 +64,7 @@ func Connect(networkName string, cinfo *container.ContainerInfo) error {
 		PortMapping: cinfo.PortMapping,
 	}
 
-	err = drivers[network.Driver].Connect(network, ep)
+	err := drivers[network.Driver].Connect(network, ep)
 	if err != nil {
 		logrus.Errorf("调用驱动设置网络失败 %v", err)
 		return err
@@ -75,6 +80,53 @@ func Connect(networkName string, cinfo *container.ContainerInfo) error {
 	return configPortMapping(ep, cinfo)
 }
 
+// Disconnect 把容器从网络中移除 删除端口映射 释放容器的ip
+func Disconnect(networkName string, cinfo *container.ContainerInfo) error {
+	network, ok := networks[networkName]
+	if !ok {
+		return fmt.Errorf("没有该网络 %s", networkName)
+	}
+	ep := &Endpoint{
+		ID:          fmt.Sprintf("%s-%s", cinfo.Id, networkName),
+		IPAddress:   net.ParseIP(cinfo.IPAddress),
+		Network:     network,
+		PortMapping: cinfo.PortMapping,
+	}
+	ep.Device = netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: ep.ID[:5]},
+		PeerName:  "cif-" + ep.ID[:5],
+	}
+	if err := drivers[network.Driver].DisConnect(network, ep); err != nil {
+		logrus.Errorf("调用驱动删除网络端点失败 %v", err)
+		return err
+	}
+	if ep.IPAddress != nil {
+		removePortMapping(ep)
+		if err := ipAllocator.Release(network.IpRange, &ep.IPAddress); err != nil {
+			logrus.Errorf("释放ip失败 %v", err)
+			return err
+		}
+	}
+	cinfo.IPAddress = ""
+	return nil
+}
+
+func removePortMapping(ep *Endpoint) {
+	for _, pm := range ep.PortMapping {
+		portMapping := strings.Split(pm, ":")
+		if len(portMapping) != 2 {
+			continue
+		}
+		iptablesCmd := fmt.Sprintf(
+			"-t nat -D PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
+			portMapping[0], ep.IPAddress.String(), portMapping[1])
+		cmd := exec.Command("iptables", strings.Split(iptablesCmd, " ")...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			logrus.Errorf("删除端口映射失败 %s %v", output, err)
+		}
+	}
+}
+
 func getGw(networkName string) *net.IP {
 	br, err := net.InterfaceByName(networkName)
 	if err != nil {
@@ -99,9 +151,15 @@ func configPortMapping(ep *Endpoint, cinfo *container.ContainerInfo) error {
 		}
 
 		// 在iptables的PREROUTING中添加DNAT规则
-		iptablesCmd := fmt.Sprintf(
-			"-t nat -A PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
+		rule := fmt.Sprintf(
+			"PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
 			portMapping[0], ep.IPAddress.String(), portMapping[1])
+		// 容器重启时规则已经存在 不重复添加
+		checkCmd := exec.Command("iptables", strings.Split("-t nat -C "+rule, " ")...)
+		if err := checkCmd.Run(); err == nil {
+			continue
+		}
+		iptablesCmd := "-t nat -A " + rule
 		cmd := exec.Command("iptables", strings.Split(iptablesCmd, " ")...)
 		output, err := cmd.Output()
 		if err != nil {
@@ -252,3 +310,20 @@ func DeleteNetwork(networkName string) error {
 	}
 	return nw.remove(defaultNetworkPath)
 }
+
+// GetEndpointStatistics 获取容器网络流量 veth在宿主机一端的名字是容器id的前5位
+// 宿主机一端接收的就是容器发送的 所以返回时收发互换
+func GetEndpointStatistics(containerId string) (rxBytes, txBytes uint64, err error) {
+	if len(containerId) < 5 {
+		return 0, 0, fmt.Errorf("容器id格式错误 %s", containerId)
+	}
+	link, err := netlink.LinkByName(containerId[:5])
+	if err != nil {
+		return 0, 0, err
+	}
+	statistics := link.Attrs().Statistics
+	if statistics == nil {
+		return 0, 0, nil
+	}
+	return statistics.TxBytes, statistics.RxBytes, nil
+}
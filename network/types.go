@@ -2,7 +2,6 @@ package network
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net"
@@ -48,14 +47,14 @@ func (n *Network) dump(path string) error {
 
 func (n *Network) load(path string) error {
 	nwFile, err := os.Open(path)
-	defer nwFile.Close()
 	if err != nil {
-		fmt.Errorf("打开网络文件失败 %v", err)
+		logrus.Errorf("打开网络文件失败 %v", err)
 		return err
 	}
+	defer nwFile.Close()
 	nwJson, err := ioutil.ReadAll(nwFile)
 	if err != nil {
-		fmt.Errorf("读取网络文件失败 %v", err)
+		logrus.Errorf("读取网络文件失败 %v", err)
 		return err
 	}
 	err = json.Unmarshal(nwJson, n)
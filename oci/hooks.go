@@ -0,0 +1,123 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 容器状态 hook通过stdin读取
+const (
+	StateCreating = "creating"
+	StateRunning  = "running"
+	StateStopped  = "stopped"
+)
+
+// hook的执行时机
+const (
+	HookPrestart        = "prestart"
+	HookCreateRuntime   = "createRuntime"
+	HookCreateContainer = "createContainer"
+	HookStartContainer  = "startContainer"
+	HookPoststart       = "poststart"
+	HookPoststop        = "poststop"
+)
+
+// hook没有指定timeout时最多执行的时间
+const DefaultHookTimeout = 30 * time.Second
+
+// LoadHooks 读取和spec中hooks格式相同的json文件
+func LoadHooks(path string) (*Hooks, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取hooks失败 %v", err)
+	}
+	var hooks Hooks
+	if err := json.Unmarshal(content, &hooks); err != nil {
+		return nil, fmt.Errorf("解析hooks失败 %v", err)
+	}
+	return &hooks, nil
+}
+
+// Get 返回某个时机要执行的hook h为nil时返回nil
+func (h *Hooks) Get(name string) []Hook {
+	if h == nil {
+		return nil
+	}
+	switch name {
+	case HookPrestart:
+		return h.Prestart
+	case HookCreateRuntime:
+		return h.CreateRuntime
+	case HookCreateContainer:
+		return h.CreateContainer
+	case HookStartContainer:
+		return h.StartContainer
+	case HookPoststart:
+		return h.Poststart
+	case HookPoststop:
+		return h.Poststop
+	}
+	return nil
+}
+
+// State OCI规定的容器状态 执行hook时写入hook的stdin
+type State struct {
+	Version     string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	Pid         int               `json:"pid,omitempty"`
+	Bundle      string            `json:"bundle"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// RunHooks 依次执行hooks 有一个失败就返回错误
+func RunHooks(hooks []Hook, state *State) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if err := runHook(hook, content); err != nil {
+			return fmt.Errorf("执行hook %s 失败 %v", hook.Path, err)
+		}
+	}
+	return nil
+}
+
+// 和spec一致 Args[0]是程序名 为空时使用Path 超时后杀死hook进程
+func runHook(hook Hook, state []byte) error {
+	timeout := DefaultHookTimeout
+	if hook.Timeout != nil && *hook.Timeout > 0 {
+		timeout = time.Duration(*hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Path)
+	if len(hook.Args) > 0 {
+		cmd.Args = hook.Args
+	}
+	// 没有指定env时hook不能继承yocker的环境变量 Env为nil时exec会使用当前进程的环境变量
+	cmd.Env = []string{}
+	if len(hook.Env) > 0 {
+		cmd.Env = hook.Env
+	}
+	cmd.Stdin = bytes.NewReader(state)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("执行超过 %v", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
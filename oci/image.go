@@ -0,0 +1,66 @@
+package oci
+
+// 只定义yocker用到的OCI image-spec字段
+// https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+
+const (
+	// ImageLayoutFile 和ImageLayoutVersion 标识目录是OCI镜像布局
+	ImageLayoutFile    = "oci-layout"
+	ImageLayoutVersion = "1.0.0"
+	// ImageIndexFile 镜像布局的入口
+	ImageIndexFile = "index.json"
+	// BlobsDir blob按digest保存在blobs/<算法>/<hex>
+	BlobsDir = "blobs"
+)
+
+// 镜像布局中的media type
+const (
+	MediaTypeImageIndex    = "application/vnd.oci.image.index.v1+json"
+	MediaTypeImageManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeImageConfig   = "application/vnd.oci.image.config.v1+json"
+	MediaTypeLayer         = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeLayerGzip     = "application/vnd.oci.image.layer.v1.tar+gzip"
+	MediaTypeLayerZstd     = "application/vnd.oci.image.layer.v1.tar+zstd"
+	// docker save和registry中使用的media type
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// 镜像的名字 ref.name是规范中的 containerd和docker会另外写入完整的镜像名
+const (
+	AnnotationRefName        = "org.opencontainers.image.ref.name"
+	AnnotationContainerdName = "io.containerd.image.name"
+)
+
+type ImageLayout struct {
+	Version string `json:"imageLayoutVersion"`
+}
+
+// Descriptor 指向一个blob
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// ImageIndex index.json和多平台镜像的清单
+type ImageIndex struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// Manifest 一个镜像的配置和layer 从下到上排列
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
@@ -0,0 +1,68 @@
+package oci
+
+import (
+	"strings"
+	"syscall"
+)
+
+// 挂载选项和mount flag的对应关系 clear为true表示清除该flag
+var mountFlags = map[string]struct {
+	clear bool
+	flag  uintptr
+}{
+	"ro":          {false, syscall.MS_RDONLY},
+	"rw":          {true, syscall.MS_RDONLY},
+	"nosuid":      {false, syscall.MS_NOSUID},
+	"suid":        {true, syscall.MS_NOSUID},
+	"nodev":       {false, syscall.MS_NODEV},
+	"dev":         {true, syscall.MS_NODEV},
+	"noexec":      {false, syscall.MS_NOEXEC},
+	"exec":        {true, syscall.MS_NOEXEC},
+	"sync":        {false, syscall.MS_SYNCHRONOUS},
+	"async":       {true, syscall.MS_SYNCHRONOUS},
+	"noatime":     {false, syscall.MS_NOATIME},
+	"atime":       {true, syscall.MS_NOATIME},
+	"relatime":    {false, syscall.MS_RELATIME},
+	"norelatime":  {true, syscall.MS_RELATIME},
+	"strictatime": {false, syscall.MS_STRICTATIME},
+	"bind":        {false, syscall.MS_BIND},
+	"rbind":       {false, syscall.MS_BIND | syscall.MS_REC},
+}
+
+// ParseMountOptions 把挂载选项转换为mount flag 不认识的选项作为data传给文件系统 如mode=755
+func ParseMountOptions(options []string) (uintptr, string) {
+	var flags uintptr
+	var data []string
+	for _, option := range options {
+		if f, ok := mountFlags[option]; ok {
+			if f.clear {
+				flags &^= f.flag
+			} else {
+				flags |= f.flag
+			}
+			continue
+		}
+		data = append(data, option)
+	}
+	return flags, strings.Join(data, ",")
+}
+
+// MountOptions ParseMountOptions的逆操作 用于生成spec
+func MountOptions(flags uintptr, data string) []string {
+	var options []string
+	if flags&(syscall.MS_BIND|syscall.MS_REC) == syscall.MS_BIND|syscall.MS_REC {
+		options = append(options, "rbind")
+		flags &^= syscall.MS_BIND | syscall.MS_REC
+	}
+	// 按固定顺序输出 生成的spec每次都相同
+	for _, option := range []string{"bind", "ro", "nosuid", "nodev", "noexec", "sync", "noatime", "relatime", "strictatime"} {
+		if f := mountFlags[option]; flags&f.flag != 0 {
+			options = append(options, option)
+			flags &^= f.flag
+		}
+	}
+	if data != "" {
+		options = append(options, strings.Split(data, ",")...)
+	}
+	return options
+}
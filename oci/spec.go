@@ -0,0 +1,156 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// 只定义yocker用到的OCI runtime-spec字段 其他字段在解析时被忽略
+// https://github.com/opencontainers/runtime-spec/blob/main/config.md
+
+const (
+	// Version yocker生成的spec遵循的版本
+	Version = "1.0.2"
+	// ConfigName bundle中spec文件的文件名
+	ConfigName = "config.json"
+)
+
+// namespace类型
+const (
+	PIDNamespace     = "pid"
+	NetworkNamespace = "network"
+	MountNamespace   = "mount"
+	IPCNamespace     = "ipc"
+	UTSNamespace     = "uts"
+	UserNamespace    = "user"
+	CgroupNamespace  = "cgroup"
+)
+
+type Spec struct {
+	Version  string   `json:"ociVersion"`
+	Process  *Process `json:"process,omitempty"`
+	Root     *Root    `json:"root,omitempty"`
+	Hostname string   `json:"hostname,omitempty"`
+	Mounts   []Mount  `json:"mounts,omitempty"`
+	Hooks    *Hooks   `json:"hooks,omitempty"`
+	Linux    *Linux   `json:"linux,omitempty"`
+}
+
+// Process 容器中运行的用户进程
+type Process struct {
+	Terminal bool     `json:"terminal,omitempty"`
+	User     User     `json:"user"`
+	Args     []string `json:"args"`
+	Env      []string `json:"env,omitempty"`
+	Cwd      string   `json:"cwd"`
+}
+
+type User struct {
+	UID            uint32   `json:"uid"`
+	GID            uint32   `json:"gid"`
+	AdditionalGids []uint32 `json:"additionalGids,omitempty"`
+}
+
+// Root 容器的rootfs 相对路径是相对于bundle目录的
+type Root struct {
+	Path     string `json:"path"`
+	Readonly bool   `json:"readonly,omitempty"`
+}
+
+type Mount struct {
+	Destination string   `json:"destination"`
+	Type        string   `json:"type,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+type Hooks struct {
+	Prestart        []Hook `json:"prestart,omitempty"`
+	CreateRuntime   []Hook `json:"createRuntime,omitempty"`
+	CreateContainer []Hook `json:"createContainer,omitempty"`
+	StartContainer  []Hook `json:"startContainer,omitempty"`
+	Poststart       []Hook `json:"poststart,omitempty"`
+	Poststop        []Hook `json:"poststop,omitempty"`
+}
+
+type Hook struct {
+	Path    string   `json:"path"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Timeout *int     `json:"timeout,omitempty"`
+}
+
+type Linux struct {
+	Namespaces []LinuxNamespace `json:"namespaces,omitempty"`
+	Resources  *LinuxResources  `json:"resources,omitempty"`
+}
+
+// LinuxNamespace Path不为空时表示加入已有的namespace
+type LinuxNamespace struct {
+	Type string `json:"type"`
+	Path string `json:"path,omitempty"`
+}
+
+type LinuxResources struct {
+	Memory *LinuxMemory `json:"memory,omitempty"`
+	CPU    *LinuxCPU    `json:"cpu,omitempty"`
+	Pids   *LinuxPids   `json:"pids,omitempty"`
+}
+
+type LinuxMemory struct {
+	Limit *int64 `json:"limit,omitempty"`
+}
+
+type LinuxCPU struct {
+	Shares *uint64 `json:"shares,omitempty"`
+	Quota  *int64  `json:"quota,omitempty"`
+	Period *uint64 `json:"period,omitempty"`
+	Cpus   string  `json:"cpus,omitempty"`
+}
+
+type LinuxPids struct {
+	Limit int64 `json:"limit"`
+}
+
+// LoadSpec 读取bundle目录中的config.json
+func LoadSpec(bundle string) (*Spec, error) {
+	content, err := ioutil.ReadFile(filepath.Join(bundle, ConfigName))
+	if err != nil {
+		return nil, fmt.Errorf("读取spec失败 %v", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(content, &spec); err != nil {
+		return nil, fmt.Errorf("解析spec失败 %v", err)
+	}
+	if spec.Process == nil || len(spec.Process.Args) == 0 {
+		return nil, fmt.Errorf("spec中没有指定process.args")
+	}
+	if spec.Root == nil || spec.Root.Path == "" {
+		return nil, fmt.Errorf("spec中没有指定root.path")
+	}
+	return &spec, nil
+}
+
+// RootfsPath 返回rootfs的绝对路径
+func (s *Spec) RootfsPath(bundle string) (string, error) {
+	rootfs := s.Root.Path
+	if !filepath.IsAbs(rootfs) {
+		rootfs = filepath.Join(bundle, rootfs)
+	}
+	return filepath.Abs(rootfs)
+}
+
+// HasNamespace 判断spec是否要求创建type类型的namespace
+func (s *Spec) HasNamespace(nsType string) bool {
+	if s.Linux == nil {
+		return false
+	}
+	for _, ns := range s.Linux.Namespaces {
+		if ns.Type == nsType {
+			return true
+		}
+	}
+	return false
+}